@@ -0,0 +1,114 @@
+package sechat
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// accessSections lists the access levels shown on the access tab of the room
+// info page. Each has a section with the ID "access-section-<level>".
+var accessSections = []string{
+	AccessOwner,
+	AccessReadWrite,
+	AccessReadOnly,
+	AccessRequest,
+}
+
+// Grant describes the access level that a user has been given in a room.
+type Grant struct {
+	UserID   int
+	UserName string
+	Access   string
+}
+
+// SetUserAccess changes the access level of a user in a room. level should be
+// one of AccessReadWrite, AccessReadOnly, AccessOwner, or AccessRemove. The
+// change is confirmed by an EventAccessLevelChanged event in the room.
+func (c *Conn) SetUserAccess(room, user int, level string) error {
+	_, err := c.postForm(
+		fmt.Sprintf("/rooms/setuseraccess/%d", room),
+		&url.Values{
+			"aclUserId":  {strconv.Itoa(user)},
+			"userAccess": {level},
+		},
+	)
+	return err
+}
+
+// accessGrants loads the access tab of the room info page and returns the
+// grants for the users in each section.
+func (c *Conn) accessGrants(room int) ([]*Grant, error) {
+	doc, err := c.getDocument(
+		fmt.Sprintf("/rooms/info/%d?tab=access", room),
+	)
+	if err != nil {
+		return nil, err
+	}
+	grants := []*Grant{}
+	for _, access := range accessSections {
+		sel := fmt.Sprintf("#access-section-%s", access)
+		doc.Find(sel).Find("a[href^='/users/']").Each(func(i int, s *goquery.Selection) {
+			m := userIDRegexp.FindStringSubmatch(s.AttrOr("href", ""))
+			if m == nil {
+				return
+			}
+			name := s.AttrOr("title", "")
+			if len(name) == 0 {
+				name = strings.TrimSpace(s.Text())
+			}
+			grants = append(grants, &Grant{
+				UserID:   atoi(m[1]),
+				UserName: name,
+				Access:   access,
+			})
+		})
+	}
+	return grants, nil
+}
+
+// AccessList retrieves the explicit access grants for a room. Pending access
+// requests are not included.
+func (c *Conn) AccessList(room int) ([]*Grant, error) {
+	grants, err := c.accessGrants(room)
+	if err != nil {
+		return nil, err
+	}
+	list := []*Grant{}
+	for _, g := range grants {
+		if g.Access != AccessRequest {
+			list = append(list, g)
+		}
+	}
+	return list, nil
+}
+
+// PendingAccessRequests retrieves the users that have requested access to a
+// room and are awaiting approval.
+func (c *Conn) PendingAccessRequests(room int) ([]*Grant, error) {
+	grants, err := c.accessGrants(room)
+	if err != nil {
+		return nil, err
+	}
+	list := []*Grant{}
+	for _, g := range grants {
+		if g.Access == AccessRequest {
+			list = append(list, g)
+		}
+	}
+	return list, nil
+}
+
+// ApproveAccessRequest grants read-write access to a user who has requested
+// access to a room.
+func (c *Conn) ApproveAccessRequest(room, user int) error {
+	return c.SetUserAccess(room, user, AccessReadWrite)
+}
+
+// DenyAccessRequest rejects a pending access request for a room.
+func (c *Conn) DenyAccessRequest(room, user int) error {
+	return c.SetUserAccess(room, user, AccessRemove)
+}
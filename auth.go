@@ -161,38 +161,40 @@ func (c *Conn) completeLogin(authUrl string) error {
 	}
 }
 
-// fetchChatFkey loads the home page for chat in order to retrieve the fkey
-// that is required to accompany every authenticated request.
-func (c *Conn) fetchChatFkeyAndUserID() (string, int, error) {
+// fetchChatFkeyAndUser loads the home page for chat in order to retrieve the
+// fkey that is required to accompany every authenticated request along with
+// the ID and name of the current user.
+func (c *Conn) fetchChatFkeyAndUser() (string, int, string, error) {
 	req, err := c.newRequest(
 		http.MethodGet,
 		"https://chat.stackexchange.com",
 		nil,
 	)
 	if err != nil {
-		return "", 0, err
+		return "", 0, "", err
 	}
 	res, err := c.client.Do(req)
 	if err != nil {
-		return "", 0, err
+		return "", 0, "", err
 	}
 	doc, err := goquery.NewDocumentFromReader(res.Body)
 	if err != nil {
-		return "", 0, err
+		return "", 0, "", err
 	}
 	fkey, ok := doc.Find("#fkey").Attr("value")
 	if !ok {
-		return "", 0, ErrChatFkey
+		return "", 0, "", ErrChatFkey
 	}
-	userID, ok := doc.Find(".topbar-menu-links a").Attr("href")
+	userLink := doc.Find(".topbar-menu-links a").First()
+	userID, ok := userLink.Attr("href")
 	if !ok {
-		return "", 0, ErrChatUserID
+		return "", 0, "", ErrChatUserID
 	}
 	m := userIDRegexp.FindStringSubmatch(userID)
 	if m == nil {
-		return "", 0, ErrChatUserID
+		return "", 0, "", ErrChatUserID
 	}
-	return fkey, atoi(m[1]), nil
+	return fkey, atoi(m[1]), strings.TrimSpace(userLink.Text()), nil
 }
 
 // auth performs the steps necessary to authenticate against the chat server.
@@ -212,11 +214,14 @@ func (c *Conn) auth() error {
 	if err := c.completeLogin(authURL); err != nil {
 		return err
 	}
-	chatFkey, userID, err := c.fetchChatFkeyAndUserID()
+	chatFkey, userID, userName, err := c.fetchChatFkeyAndUser()
 	if err != nil {
 		return err
 	}
 	c.fkey = chatFkey
+	c.mutex.Lock()
 	c.user = userID
+	c.userName = userName
+	c.mutex.Unlock()
 	return nil
 }
@@ -0,0 +1,326 @@
+package bridge
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nathan-osman/go-sechat"
+	"github.com/nathan-osman/go-sechat/format"
+)
+
+// matrixEvent is an event received from the homeserver in a transaction.
+type matrixEvent struct {
+	Type    string          `json:"type"`
+	EventID string          `json:"event_id"`
+	RoomID  string          `json:"room_id"`
+	Sender  string          `json:"sender"`
+	Redacts string          `json:"redacts"`
+	Content json.RawMessage `json:"content"`
+}
+
+// relatesTo describes the relationship between an event and an earlier one.
+type relatesTo struct {
+	RelType   string `json:"rel_type"`
+	EventID   string `json:"event_id"`
+	Key       string `json:"key"`
+	InReplyTo *struct {
+		EventID string `json:"event_id"`
+	} `json:"m.in_reply_to"`
+}
+
+// messageEventContent is the content of an m.room.message event.
+type messageEventContent struct {
+	MsgType    string               `json:"msgtype"`
+	Body       string               `json:"body"`
+	URL        string               `json:"url"`
+	NewContent *messageEventContent `json:"m.new_content"`
+	RelatesTo  *relatesTo           `json:"m.relates_to"`
+	Redacts    string               `json:"redacts"`
+}
+
+// writeJSON sends a JSON response.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError sends a Matrix error response.
+func writeError(w http.ResponseWriter, status int, errCode, message string) {
+	writeJSON(w, status, map[string]string{
+		"errcode": errCode,
+		"error":   message,
+	})
+}
+
+// ServeHTTP implements the application service API used by the homeserver.
+func (b *Bridge) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if len(token) == 0 {
+		token = r.URL.Query().Get("access_token")
+	}
+	if subtle.ConstantTimeCompare([]byte(token), []byte(b.cfg.HSToken)) != 1 {
+		writeError(w, http.StatusForbidden, "M_FORBIDDEN", "invalid token")
+		return
+	}
+	path := strings.TrimPrefix(r.URL.EscapedPath(), "/_matrix/app/v1")
+	parts := strings.SplitN(strings.TrimPrefix(path, "/"), "/", 2)
+	if len(parts) != 2 {
+		if parts[0] == "ping" && r.Method == http.MethodPost {
+			writeJSON(w, http.StatusOK, struct{}{})
+			return
+		}
+		writeError(w, http.StatusNotFound, "M_UNRECOGNIZED", "unknown endpoint")
+		return
+	}
+	arg, err := url.PathUnescape(parts[1])
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "M_INVALID_PARAM", err.Error())
+		return
+	}
+	switch {
+	case parts[0] == "transactions" && r.Method == http.MethodPut:
+		b.transaction(w, r, arg)
+	case parts[0] == "users" && r.Method == http.MethodGet:
+		b.queryUser(w, arg)
+	case parts[0] == "rooms" && r.Method == http.MethodGet:
+		b.queryAlias(w, arg)
+	default:
+		writeError(w, http.StatusNotFound, "M_UNRECOGNIZED", "unknown endpoint")
+	}
+}
+
+// transaction accepts a batch of events from the homeserver. Transactions are
+// retried by the homeserver, so duplicates are ignored. The events in a
+// transaction are queued together; if the queue stays full, the homeserver is
+// asked to retry the transaction later.
+func (b *Bridge) transaction(w http.ResponseWriter, r *http.Request, txnID string) {
+	var v struct {
+		Events []*matrixEvent `json:"events"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&v); err != nil {
+		writeError(w, http.StatusBadRequest, "M_NOT_JSON", err.Error())
+		return
+	}
+	if b.addTransaction(txnID) {
+		fn := func() {
+			for _, e := range v.Events {
+				if err := b.handleMatrix(e); err != nil {
+					b.log.Error(err)
+				}
+			}
+		}
+		select {
+		case b.queue <- fn:
+		case <-time.After(queueTimeout):
+			b.removeTransaction(txnID)
+			writeError(w, http.StatusTooManyRequests, "M_LIMIT_EXCEEDED", "too many pending events")
+			return
+		}
+	}
+	writeJSON(w, http.StatusOK, struct{}{})
+}
+
+// queryUser registers ghost users that the homeserver asks about.
+func (b *Bridge) queryUser(w http.ResponseWriter, userID string) {
+	prefix := "@" + b.cfg.Prefix
+	if !strings.HasPrefix(userID, prefix) {
+		writeError(w, http.StatusNotFound, "M_NOT_FOUND", "user not found")
+		return
+	}
+	localpart := strings.SplitN(strings.TrimPrefix(userID, "@"), ":", 2)[0]
+	if err := b.matrix.register(localpart); err != nil {
+		writeError(w, http.StatusInternalServerError, "M_UNKNOWN", err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, struct{}{})
+}
+
+// queryAlias creates a Matrix room for a chat room when an alias of the form
+// #<prefix><room>:<domain> is requested.
+func (b *Bridge) queryAlias(w http.ResponseWriter, alias string) {
+	var (
+		localpart = strings.SplitN(strings.TrimPrefix(alias, "#"), ":", 2)[0]
+		room, err = strconv.Atoi(strings.TrimPrefix(localpart, b.cfg.Prefix))
+	)
+	if !strings.HasPrefix(localpart, b.cfg.Prefix) || err != nil || room <= 0 {
+		writeError(w, http.StatusNotFound, "M_NOT_FOUND", "room not found")
+		return
+	}
+	if err := b.conn.Join(room); err != nil {
+		writeError(w, http.StatusNotFound, "M_NOT_FOUND", err.Error())
+		return
+	}
+	roomID, err := b.matrix.createRoom(
+		localpart,
+		fmt.Sprintf("Chat room %d", room),
+		fmt.Sprintf("https://chat.stackexchange.com/rooms/%d", room),
+	)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "M_UNKNOWN", err.Error())
+		return
+	}
+	b.mutex.Lock()
+	b.rooms[room] = roomID
+	b.chatRooms[roomID] = room
+	b.mutex.Unlock()
+	writeJSON(w, http.StatusOK, struct{}{})
+}
+
+// senderName returns the localpart of a Matrix user ID for use in chat.
+func senderName(userID string) string {
+	return strings.SplitN(strings.TrimPrefix(userID, "@"), ":", 2)[0]
+}
+
+// stripReplyFallback removes the quoted text that Matrix clients include at
+// the start of replies.
+func stripReplyFallback(body string) string {
+	lines := strings.Split(body, "\n")
+	i := 0
+	for i < len(lines) && strings.HasPrefix(lines[i], ">") {
+		i++
+	}
+	return strings.TrimSpace(strings.Join(lines[i:], "\n"))
+}
+
+// chatMessage returns the chat message for a Matrix event or 0.
+func (b *Bridge) chatMessage(eventID string) int {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.events[eventID]
+}
+
+// handleMatrix relays an event from Matrix to chat.
+func (b *Bridge) handleMatrix(e *matrixEvent) error {
+	if b.isBridged(e.Sender) {
+		return nil
+	}
+	b.mutex.Lock()
+	room, ok := b.chatRooms[e.RoomID]
+	b.mutex.Unlock()
+	if !ok {
+		return nil
+	}
+	content := &messageEventContent{}
+	if err := json.Unmarshal(e.Content, content); err != nil {
+		return err
+	}
+	switch e.Type {
+	case "m.room.message":
+		return b.handleMatrixMessage(room, e, content)
+	case "m.reaction":
+		if content.RelatesTo == nil ||
+			!strings.HasPrefix(content.RelatesTo.Key, starKey) {
+			return nil
+		}
+		if m := b.chatMessage(content.RelatesTo.EventID); m != 0 {
+			return b.addReaction(m, e.EventID)
+		}
+	case "m.room.redaction":
+		redacts := e.Redacts
+		if len(redacts) == 0 {
+			redacts = content.Redacts
+		}
+		b.mutex.Lock()
+		m, isReaction := b.reacted[redacts]
+		b.mutex.Unlock()
+		if isReaction {
+			return b.removeReaction(m, redacts)
+		}
+		if m := b.chatMessage(redacts); m != 0 {
+			b.expectEcho(sechat.EventMessageDeleted, m)
+			if err := b.conn.Delete(m); err != nil {
+				b.consumeEcho(sechat.EventMessageDeleted, m)
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// addReaction records a star reaction from Matrix. Starring in chat toggles,
+// so the message is only starred for the first reaction.
+func (b *Bridge) addReaction(message int, eventID string) error {
+	b.mutex.Lock()
+	reactions, ok := b.reactions[message]
+	if !ok {
+		reactions = map[string]struct{}{}
+		b.reactions[message] = reactions
+	}
+	reactions[eventID] = struct{}{}
+	b.reacted[eventID] = message
+	first := len(reactions) == 1
+	b.mutex.Unlock()
+	if first {
+		return b.conn.Star(message)
+	}
+	return nil
+}
+
+// removeReaction removes a star reaction that was redacted in Matrix. The
+// star is removed in chat (by toggling it again) when the last reaction is
+// removed.
+func (b *Bridge) removeReaction(message int, eventID string) error {
+	b.mutex.Lock()
+	reactions := b.reactions[message]
+	delete(reactions, eventID)
+	delete(b.reacted, eventID)
+	last := len(reactions) == 0
+	if last {
+		delete(b.reactions, message)
+	}
+	b.mutex.Unlock()
+	if last {
+		return b.conn.Star(message)
+	}
+	return nil
+}
+
+// handleMatrixMessage posts, edits, or uploads a message from Matrix.
+func (b *Bridge) handleMatrixMessage(room int, e *matrixEvent, content *messageEventContent) error {
+	prefix := format.Bold(format.Escape(senderName(e.Sender))) + ": "
+	if content.RelatesTo != nil && content.RelatesTo.RelType == "m.replace" {
+		m := b.chatMessage(content.RelatesTo.EventID)
+		if m == 0 || content.NewContent == nil {
+			return nil
+		}
+		b.expectEcho(sechat.EventMessageEdited, m)
+		if err := b.conn.Edit(m, prefix+content.NewContent.Body); err != nil {
+			b.consumeEcho(sechat.EventMessageEdited, m)
+			return err
+		}
+		return nil
+	}
+	var text string
+	switch content.MsgType {
+	case "m.text", "m.notice":
+		text = prefix + stripReplyFallback(content.Body)
+	case "m.emote":
+		text = format.Italic(format.Escape(senderName(e.Sender)) + " " + content.Body)
+	case "m.image":
+		r, err := b.matrix.download(content.URL)
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+		u, err := b.conn.UploadImage(r, nil)
+		if err != nil {
+			return err
+		}
+		text = prefix + u
+	default:
+		return nil
+	}
+	if content.RelatesTo != nil && content.RelatesTo.InReplyTo != nil {
+		if m := b.chatMessage(content.RelatesTo.InReplyTo.EventID); m != 0 {
+			text = format.Reply(m, text)
+		}
+	}
+	return b.send(room, text, e)
+}
@@ -0,0 +1,312 @@
+/*
+Package bridge implements a Matrix application service that mirrors chat rooms
+into rooms on a Matrix homeserver.
+
+Each chat user is represented in Matrix by a "ghost" user whose localpart is
+the configured prefix followed by their chat ID. Messages, edits, deletions,
+and stars are forwarded in both directions. Messages sent from Matrix are
+posted by the chat account used by the bridge, prefixed with the name of the
+Matrix user, and images are uploaded to chat with Image().
+
+The bridge must be registered with the homeserver using a registration file
+that reserves the user and alias namespaces:
+
+    id: sechat
+    url: http://localhost:8009
+    as_token: <ASToken>
+    hs_token: <HSToken>
+    sender_localpart: sechat
+    namespaces:
+      users:
+        - exclusive: true
+          regex: "@sechat_.*"
+      aliases:
+        - exclusive: true
+          regex: "#sechat_.*"
+
+To run the bridge:
+
+    b, err := bridge.New(c, &bridge.Config{...})
+    if err != nil {
+        // handle error
+    }
+    go b.Run()
+    http.ListenAndServe(":8009", b)
+
+Rooms listed in Config.Rooms are bridged immediately. Other rooms can be
+joined from Matrix using an alias such as #sechat_201:example.com. The
+mapping between messages and events is kept in memory, so edits and deletions
+of messages from before the bridge was started are not forwarded.
+*/
+package bridge
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nathan-osman/go-sechat"
+	"github.com/sirupsen/logrus"
+)
+
+// Config provides the settings needed to connect to the homeserver.
+type Config struct {
+	// HomeserverURL is the base URL of the client-server API
+	HomeserverURL string
+
+	// Domain is the server name used in user IDs and aliases
+	Domain string
+
+	// ASToken and HSToken must match the registration file
+	ASToken string
+	HSToken string
+
+	// BotLocalpart is the sender_localpart from the registration file
+	BotLocalpart string
+
+	// Prefix is used for the localparts of ghost users and room aliases
+	Prefix string
+
+	// Rooms maps chat rooms to existing Matrix room IDs
+	Rooms map[int]string
+}
+
+var (
+	ErrTokens = errors.New("ASToken and HSToken must be set")
+	ErrPrefix = errors.New("prefix must be set")
+)
+
+// maxTransactions is the number of recent transaction IDs remembered in order
+// to ignore transactions retried by the homeserver.
+const maxTransactions = 1000
+
+// queueTimeout is the longest that a transaction waits for room in the queue.
+// If the queue remains full, the homeserver is asked to retry later.
+const queueTimeout = 10 * time.Second
+
+// echoTimeout is the longest that a message posted by the bridge's chat
+// account waits for an in-progress post from Matrix to finish so that it can
+// tell whether it is an echo.
+const echoTimeout = 2 * time.Second
+
+// echoKey identifies a chat event that the bridge caused itself.
+type echoKey struct {
+	eventType int
+	message   int
+}
+
+// matrixMessage identifies a Matrix event and the user that sent it.
+type matrixMessage struct {
+	roomID  string
+	eventID string
+	userID  string
+}
+
+// Bridge relays events between chat and a Matrix homeserver.
+type Bridge struct {
+	conn      *sechat.Conn
+	cfg       *Config
+	matrix    *matrixClient
+	log       *logrus.Entry
+	queue     chan func()
+	mutex     sync.Mutex
+	rooms     map[int]string
+	chatRooms map[string]int
+	ghosts    map[int]string
+	members   map[string]struct{}
+	messages  map[int]*matrixMessage
+	events    map[string]int
+	stars     map[int]string
+	reactions map[int]map[string]struct{}
+	reacted   map[string]int
+	echoes    map[echoKey]int
+	sending   map[int]int
+	sentCh    chan bool
+	txns      map[string]struct{}
+	txnOrder  []string
+}
+
+// New creates a bridge for the connection using the provided settings. Both
+// tokens and the prefix are required; without them, any request would be
+// accepted and every user would be treated as belonging to the bridge.
+func New(c *sechat.Conn, cfg *Config) (*Bridge, error) {
+	if len(cfg.ASToken) == 0 || len(cfg.HSToken) == 0 {
+		return nil, ErrTokens
+	}
+	if len(cfg.Prefix) == 0 {
+		return nil, ErrPrefix
+	}
+	b := &Bridge{
+		conn:      c,
+		cfg:       cfg,
+		matrix:    newMatrixClient(cfg.HomeserverURL, cfg.ASToken),
+		log:       logrus.WithField("context", "bridge"),
+		queue:     make(chan func(), 100),
+		rooms:     map[int]string{},
+		chatRooms: map[string]int{},
+		ghosts:    map[int]string{},
+		members:   map[string]struct{}{},
+		messages:  map[int]*matrixMessage{},
+		events:    map[string]int{},
+		stars:     map[int]string{},
+		reactions: map[int]map[string]struct{}{},
+		reacted:   map[string]int{},
+		echoes:    map[echoKey]int{},
+		sending:   map[int]int{},
+		sentCh:    make(chan bool),
+		txns:      map[string]struct{}{},
+		txnOrder:  []string{},
+	}
+	for room, roomID := range cfg.Rooms {
+		b.rooms[room] = roomID
+		b.chatRooms[roomID] = room
+	}
+	go b.process()
+	return b, nil
+}
+
+// process runs the actions triggered by Matrix in order. Posting to chat may
+// block while throttled, so this is done outside of the HTTP handler.
+func (b *Bridge) process() {
+	for fn := range b.queue {
+		fn()
+	}
+}
+
+// addTransaction records a transaction ID, returning false if it was already
+// recorded. Only the most recent IDs are kept.
+func (b *Bridge) addTransaction(txnID string) bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if _, exists := b.txns[txnID]; exists {
+		return false
+	}
+	b.txns[txnID] = struct{}{}
+	b.txnOrder = append(b.txnOrder, txnID)
+	for len(b.txnOrder) > maxTransactions {
+		delete(b.txns, b.txnOrder[0])
+		b.txnOrder = b.txnOrder[1:]
+	}
+	return true
+}
+
+// removeTransaction forgets a transaction ID so that the transaction is
+// processed when it is retried.
+func (b *Bridge) removeTransaction(txnID string) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	delete(b.txns, txnID)
+	for i := len(b.txnOrder) - 1; i >= 0; i-- {
+		if b.txnOrder[i] == txnID {
+			b.txnOrder = append(b.txnOrder[:i:i], b.txnOrder[i+1:]...)
+			break
+		}
+	}
+}
+
+// expectEcho records that the bridge is about to cause a chat event for a
+// message so that it is not relayed back to Matrix. It must be called before
+// the action since the event may arrive before the action returns.
+func (b *Bridge) expectEcho(eventType, message int) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.echoes[echoKey{eventType, message}]++
+}
+
+// consumeEcho determines if a chat event was caused by the bridge, removing
+// the record of it. It is also used to remove the record if the action fails.
+func (b *Bridge) consumeEcho(eventType, message int) bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	k := echoKey{eventType, message}
+	if b.echoes[k] == 0 {
+		return false
+	}
+	if b.echoes[k]--; b.echoes[k] == 0 {
+		delete(b.echoes, k)
+	}
+	return true
+}
+
+// send posts a message from Matrix to chat, recording it along with the
+// Matrix event. Until the post finishes, messages from the bridge's chat
+// account in the room are held by waitForSend since they may be the echo.
+func (b *Bridge) send(room int, text string, e *matrixEvent) error {
+	b.mutex.Lock()
+	b.sending[room]++
+	b.mutex.Unlock()
+	m, err := b.conn.SendMessage(room, text)
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if err == nil {
+		b.events[e.EventID] = m
+		b.messages[m] = &matrixMessage{
+			roomID:  e.RoomID,
+			eventID: e.EventID,
+			userID:  e.Sender,
+		}
+	}
+	b.sending[room]--
+	close(b.sentCh)
+	b.sentCh = make(chan bool)
+	return err
+}
+
+// waitForSend waits (up to echoTimeout) for posts from Matrix to the event's
+// room to finish so that handlePosted can recognize the event as an echo.
+func (b *Bridge) waitForSend(e *sechat.Event) {
+	timeoutCh := time.After(echoTimeout)
+	for {
+		b.mutex.Lock()
+		var (
+			_, exists = b.messages[e.MessageID]
+			sending   = b.sending[e.RoomID]
+			sentCh    = b.sentCh
+		)
+		b.mutex.Unlock()
+		if exists || sending == 0 {
+			return
+		}
+		select {
+		case <-sentCh:
+		case <-timeoutCh:
+			return
+		}
+	}
+}
+
+// botID returns the Matrix ID of the bridge's own user.
+func (b *Bridge) botID() string {
+	return fmt.Sprintf("@%s:%s", b.cfg.BotLocalpart, b.cfg.Domain)
+}
+
+// ghostLocalpart returns the localpart of the ghost for a chat user.
+func (b *Bridge) ghostLocalpart(user int) string {
+	return fmt.Sprintf("%s%d", b.cfg.Prefix, user)
+}
+
+// ghostID returns the Matrix ID of the ghost for a chat user.
+func (b *Bridge) ghostID(user int) string {
+	return fmt.Sprintf("@%s:%s", b.ghostLocalpart(user), b.cfg.Domain)
+}
+
+// isBridged determines if a Matrix user belongs to the bridge.
+func (b *Bridge) isBridged(userID string) bool {
+	return userID == b.botID() ||
+		strings.HasPrefix(userID, "@"+b.cfg.Prefix)
+}
+
+// Run joins the configured rooms and relays events from the connection until
+// it is closed. Alternatively, events may be passed to Handle().
+func (b *Bridge) Run() {
+	for room := range b.cfg.Rooms {
+		if err := b.conn.Join(room); err != nil {
+			b.log.Error(err)
+		}
+	}
+	for e := range b.conn.Events {
+		b.Handle(e)
+	}
+}
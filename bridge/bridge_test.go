@@ -0,0 +1,328 @@
+package bridge
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nathan-osman/go-sechat"
+)
+
+// testRequest is a request received by one of the fake servers.
+type testRequest struct {
+	method string
+	path   string
+	user   string
+	text   string
+	body   map[string]interface{}
+}
+
+// testRecorder records the requests received by a fake server.
+type testRecorder struct {
+	mutex    sync.Mutex
+	requests []*testRequest
+}
+
+func (r *testRecorder) add(req *testRequest) int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.requests = append(r.requests, req)
+	return len(r.requests)
+}
+
+// wait returns the requests once there are at least n of them.
+func (r *testRecorder) wait(t *testing.T, n int) []*testRequest {
+	timeoutCh := time.After(time.Second)
+	for {
+		r.mutex.Lock()
+		requests := append([]*testRequest{}, r.requests...)
+		r.mutex.Unlock()
+		if len(requests) >= n {
+			return requests
+		}
+		select {
+		case <-time.After(5 * time.Millisecond):
+		case <-timeoutCh:
+			t.Fatalf("%d request(s) != %d", len(requests), n)
+		}
+	}
+}
+
+// newTestBridge creates a bridge connected to a fake homeserver and a fake
+// chat server. The chat user is 1 and room 201 is bridged to !room.
+func newTestBridge(t *testing.T) (*Bridge, *testRecorder, *testRecorder) {
+	var (
+		matrix = &testRecorder{}
+		chat   = &testRecorder{}
+	)
+	hs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer as" {
+			t.Errorf("unexpected authorization %q", r.Header.Get("Authorization"))
+		}
+		req := &testRequest{
+			method: r.Method,
+			path:   r.URL.Path,
+			user:   r.URL.Query().Get("user_id"),
+			body:   map[string]interface{}{},
+		}
+		json.NewDecoder(r.Body).Decode(&req.body)
+		n := matrix.add(req)
+		fmt.Fprintf(w, `{"event_id":"$ev%d"}`, n)
+	}))
+	t.Cleanup(hs.Close)
+	c := sechat.NewTestConn(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := chat.add(&testRequest{
+			method: r.Method,
+			path:   r.URL.Path,
+			text:   r.FormValue("text"),
+		})
+		fmt.Fprintf(w, `{"id":%d}`, n)
+	}), 1, "bridge")
+	b, err := New(c, &Config{
+		HomeserverURL: hs.URL,
+		Domain:        "example.com",
+		ASToken:       "as",
+		HSToken:       "hs",
+		BotLocalpart:  "sechat",
+		Prefix:        "sechat_",
+		Rooms:         map[int]string{201: "!room"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b, matrix, chat
+}
+
+// put sends a transaction containing the events to the bridge.
+func put(t *testing.T, b *Bridge, txnID string, events ...string) {
+	r := httptest.NewRequest(
+		http.MethodPut,
+		"/_matrix/app/v1/transactions/"+txnID,
+		strings.NewReader(fmt.Sprintf(`{"events":[%s]}`, strings.Join(events, ","))),
+	)
+	r.Header.Set("Authorization", "Bearer hs")
+	w := httptest.NewRecorder()
+	b.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("%s: %d", txnID, w.Code)
+	}
+}
+
+// matrixEventJSON creates an event sent to !room.
+func matrixEventJSON(eventType, eventID, sender, content string) string {
+	return fmt.Sprintf(
+		`{"type":%q,"event_id":%q,"room_id":"!room","sender":%q,"content":%s}`,
+		eventType, eventID, sender, content,
+	)
+}
+
+func TestNew(t *testing.T) {
+	for _, test := range []struct {
+		cfg *Config
+		err error
+	}{
+		{&Config{HSToken: "hs", Prefix: "p"}, ErrTokens},
+		{&Config{ASToken: "as", Prefix: "p"}, ErrTokens},
+		{&Config{ASToken: "as", HSToken: "hs"}, ErrPrefix},
+		{&Config{ASToken: "as", HSToken: "hs", Prefix: "p"}, nil},
+	} {
+		if _, err := New(nil, test.cfg); err != test.err {
+			t.Fatalf("%+v: %v != %v", test.cfg, err, test.err)
+		}
+	}
+}
+
+func TestServeHTTPAuth(t *testing.T) {
+	b, _, _ := newTestBridge(t)
+	for _, test := range []struct {
+		header string
+		query  string
+		status int
+	}{
+		{"", "", http.StatusForbidden},
+		{"Bearer ", "", http.StatusForbidden},
+		{"Bearer wrong", "", http.StatusForbidden},
+		{"", "?access_token=wrong", http.StatusForbidden},
+		{"Bearer hs", "", http.StatusOK},
+		{"", "?access_token=hs", http.StatusOK},
+	} {
+		r := httptest.NewRequest(http.MethodPost, "/_matrix/app/v1/ping"+test.query, nil)
+		if len(test.header) != 0 {
+			r.Header.Set("Authorization", test.header)
+		}
+		w := httptest.NewRecorder()
+		b.ServeHTTP(w, r)
+		if w.Code != test.status {
+			t.Fatalf("%q, %q: %d != %d", test.header, test.query, w.Code, test.status)
+		}
+	}
+}
+
+func TestTransactions(t *testing.T) {
+	b, _, chat := newTestBridge(t)
+	var (
+		first  = matrixEventJSON("m.room.message", "$m1", "@alice:example.com", `{"msgtype":"m.text","body":"hello"}`)
+		ghost  = matrixEventJSON("m.room.message", "$m2", "@sechat_2:example.com", `{"msgtype":"m.text","body":"echo"}`)
+		second = matrixEventJSON("m.room.message", "$m3", "@alice:example.com", `{"msgtype":"m.text","body":"world"}`)
+	)
+	put(t, b, "1", first, ghost)
+	put(t, b, "1", first, ghost)
+	put(t, b, "2", second)
+	requests := chat.wait(t, 2)
+	time.Sleep(20 * time.Millisecond)
+	if len(chat.wait(t, 2)) != 2 {
+		t.Fatal("duplicate transaction processed")
+	}
+	for i, text := range []string{"**alice**: hello", "**alice**: world"} {
+		if requests[i].text != text {
+			t.Fatalf("%q != %q", requests[i].text, text)
+		}
+	}
+	// Only the most recent transactions are remembered
+	for i := 0; i < maxTransactions+10; i++ {
+		b.addTransaction(fmt.Sprintf("x%d", i))
+	}
+	b.mutex.Lock()
+	n := len(b.txns)
+	b.mutex.Unlock()
+	if n != maxTransactions {
+		t.Fatalf("%d != %d", n, maxTransactions)
+	}
+	if !b.addTransaction("1") {
+		t.Fatal("old transaction not forgotten")
+	}
+}
+
+func TestMatrixToChat(t *testing.T) {
+	b, _, chat := newTestBridge(t)
+	put(t, b, "1",
+		matrixEventJSON("m.room.message", "$m1", "@alice:example.com", `{"msgtype":"m.text","body":"hello"}`),
+		matrixEventJSON("m.room.message", "$m2", "@bob:example.com",
+			`{"msgtype":"m.text","body":"> <@alice:example.com> hello\n\nhi","m.relates_to":{"m.in_reply_to":{"event_id":"$m1"}}}`),
+		matrixEventJSON("m.room.message", "$m3", "@alice:example.com",
+			`{"msgtype":"m.text","body":"* hey","m.new_content":{"msgtype":"m.text","body":"hey"},"m.relates_to":{"rel_type":"m.replace","event_id":"$m1"}}`),
+		matrixEventJSON("m.reaction", "$r1", "@alice:example.com", `{"m.relates_to":{"rel_type":"m.annotation","event_id":"$m2","key":"⭐"}}`),
+		matrixEventJSON("m.reaction", "$r2", "@bob:example.com", `{"m.relates_to":{"rel_type":"m.annotation","event_id":"$m2","key":"⭐"}}`),
+		matrixEventJSON("m.room.redaction", "$x1", "@alice:example.com", `{"redacts":"$r1"}`),
+		matrixEventJSON("m.room.redaction", "$x2", "@bob:example.com", `{"redacts":"$r2"}`),
+		matrixEventJSON("m.room.redaction", "$x3", "@alice:example.com", `{"redacts":"$m1"}`),
+	)
+	requests := chat.wait(t, 6)
+	for i, test := range []struct {
+		path string
+		text string
+	}{
+		{"/chats/201/messages/new", "**alice**: hello"},
+		{"/chats/201/messages/new", ":1 **bob**: hi"},
+		{"/messages/1", "**alice**: hey"},
+		// Starring toggles, so only the first and last reactions star
+		{"/messages/2/star", ""},
+		{"/messages/2/star", ""},
+		{"/messages/1/delete", ""},
+	} {
+		if r := requests[i]; r.path != test.path || r.text != test.text {
+			t.Fatalf("%d: %s %q", i, r.path, r.text)
+		}
+	}
+	// The echoes of the edit and deletion are not relayed back
+	if !b.consumeEcho(sechat.EventMessageEdited, 1) || !b.consumeEcho(sechat.EventMessageDeleted, 1) {
+		t.Fatal("echo not expected")
+	}
+}
+
+func TestChatToMatrix(t *testing.T) {
+	b, matrix, _ := newTestBridge(t)
+	b.Handle(&sechat.Event{EventType: sechat.EventMessagePosted, MessageID: 10, RoomID: 201, UserID: 2, UserName: "Carol", Content: "hi", TextContent: "hi"})
+	b.Handle(&sechat.Event{EventType: sechat.EventMessagePosted, MessageID: 11, RoomID: 201, UserID: 2, UserName: "Carol", ParentID: 10, TextContent: "re"})
+	b.Handle(&sechat.Event{EventType: sechat.EventMessageEdited, MessageID: 10, RoomID: 201, UserID: 2, UserName: "Carol", TextContent: "hey"})
+	b.Handle(&sechat.Event{EventType: sechat.EventMessageStarred, MessageID: 10, RoomID: 201, MessageStars: 1})
+	b.Handle(&sechat.Event{EventType: sechat.EventMessageStarred, MessageID: 10, RoomID: 201, MessageStars: 2})
+	b.Handle(&sechat.Event{EventType: sechat.EventMessageStarred, MessageID: 10, RoomID: 201, MessageStars: 0})
+	b.Handle(&sechat.Event{EventType: sechat.EventMessageDeleted, MessageID: 11, RoomID: 201})
+	b.Handle(&sechat.Event{EventType: sechat.EventMessagePosted, MessageID: 12, RoomID: 202, UserID: 2})
+	requests := matrix.wait(t, 0)
+	// The ghost is registered, named, and joined before its first message
+	if len(requests) != 10 {
+		t.Fatalf("%d != 10", len(requests))
+	}
+	for i, test := range []struct {
+		method string
+		path   string
+		user   string
+	}{
+		{http.MethodPost, "/_matrix/client/v3/register", ""},
+		{http.MethodPut, "/_matrix/client/v3/profile/@sechat_2:example.com/displayname", "@sechat_2:example.com"},
+		{http.MethodPost, "/_matrix/client/v3/rooms/!room/invite", ""},
+		{http.MethodPost, "/_matrix/client/v3/join/!room", "@sechat_2:example.com"},
+		{http.MethodPut, "/_matrix/client/v3/rooms/!room/send/m.room.message/", "@sechat_2:example.com"},
+		{http.MethodPut, "/_matrix/client/v3/rooms/!room/send/m.room.message/", "@sechat_2:example.com"},
+		{http.MethodPut, "/_matrix/client/v3/rooms/!room/send/m.room.message/", "@sechat_2:example.com"},
+		{http.MethodPut, "/_matrix/client/v3/rooms/!room/send/m.reaction/", ""},
+		{http.MethodPut, "/_matrix/client/v3/rooms/!room/redact/$ev8/", ""},
+		{http.MethodPut, "/_matrix/client/v3/rooms/!room/redact/$ev6/", "@sechat_2:example.com"},
+	} {
+		r := requests[i]
+		if r.method != test.method || !strings.HasPrefix(r.path, test.path) || r.user != test.user {
+			t.Fatalf("%d: %s %s %s", i, r.method, r.path, r.user)
+		}
+	}
+	reply, _ := requests[5].body["m.relates_to"].(map[string]interface{})
+	if v, _ := reply["m.in_reply_to"].(map[string]interface{}); v["event_id"] != "$ev5" {
+		t.Fatalf("%+v", requests[5].body)
+	}
+	edit, _ := requests[6].body["m.relates_to"].(map[string]interface{})
+	if edit["rel_type"] != "m.replace" || edit["event_id"] != "$ev5" {
+		t.Fatalf("%+v", requests[6].body)
+	}
+}
+
+func TestEchoes(t *testing.T) {
+	b, _, _ := newTestBridge(t)
+	b.expectEcho(sechat.EventMessageEdited, 1)
+	b.expectEcho(sechat.EventMessageEdited, 1)
+	for i, test := range []struct {
+		eventType int
+		message   int
+		output    bool
+	}{
+		{sechat.EventMessageDeleted, 1, false},
+		{sechat.EventMessageEdited, 2, false},
+		{sechat.EventMessageEdited, 1, true},
+		{sechat.EventMessageEdited, 1, true},
+		{sechat.EventMessageEdited, 1, false},
+	} {
+		if v := b.consumeEcho(test.eventType, test.message); v != test.output {
+			t.Fatalf("%d: %v != %v", i, v, test.output)
+		}
+	}
+}
+
+func TestWaitForSend(t *testing.T) {
+	b, _, _ := newTestBridge(t)
+	b.sending[201] = 1
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		b.mutex.Lock()
+		b.messages[5] = &matrixMessage{}
+		b.sending[201]--
+		close(b.sentCh)
+		b.sentCh = make(chan bool)
+		b.mutex.Unlock()
+	}()
+	start := time.Now()
+	b.waitForSend(&sechat.Event{MessageID: 5, RoomID: 201})
+	if time.Since(start) >= echoTimeout {
+		t.Fatal("timed out waiting for send")
+	}
+	b.mutex.Lock()
+	_, exists := b.messages[5]
+	b.mutex.Unlock()
+	if !exists {
+		t.Fatal("message not recorded")
+	}
+}
@@ -0,0 +1,216 @@
+package bridge
+
+import (
+	"fmt"
+
+	"github.com/nathan-osman/go-sechat"
+)
+
+// starKey is used for reactions that correspond to stars in chat.
+const starKey = "⭐"
+
+// ensureGhost registers the ghost for a chat user, updates its display name,
+// and ensures that it has joined the room.
+func (b *Bridge) ensureGhost(user int, name, roomID string) (string, error) {
+	userID := b.ghostID(user)
+	b.mutex.Lock()
+	oldName, registered := b.ghosts[user]
+	_, member := b.members[roomID+userID]
+	b.mutex.Unlock()
+	if !registered {
+		if err := b.matrix.register(b.ghostLocalpart(user)); err != nil {
+			return "", err
+		}
+	}
+	if oldName != name && len(name) != 0 {
+		if err := b.matrix.setDisplayName(userID, name); err != nil {
+			return "", err
+		}
+	}
+	if !member {
+		// Inviting fails if the user is already a member, which is fine
+		b.matrix.invite(roomID, userID)
+		if err := b.matrix.join(roomID, userID); err != nil {
+			return "", err
+		}
+	}
+	b.mutex.Lock()
+	b.ghosts[user] = name
+	b.members[roomID+userID] = struct{}{}
+	b.mutex.Unlock()
+	return userID, nil
+}
+
+// messageContent creates the content of a Matrix message from a chat event.
+func messageContent(e *sechat.Event) map[string]interface{} {
+	body := e.Markdown
+	if len(body) == 0 {
+		body = e.TextContent
+	}
+	return map[string]interface{}{
+		"msgtype":        "m.text",
+		"body":           body,
+		"format":         "org.matrix.custom.html",
+		"formatted_body": e.Content,
+	}
+}
+
+// Handle relays a chat event to Matrix. Events in rooms that are not bridged
+// and events that echo actions taken by the bridge are ignored. Other events
+// involving the bridge's chat account (such as stars on messages from Matrix)
+// are relayed.
+func (b *Bridge) Handle(e *sechat.Event) {
+	b.mutex.Lock()
+	roomID, ok := b.rooms[e.RoomID]
+	b.mutex.Unlock()
+	if !ok {
+		return
+	}
+	var err error
+	switch e.EventType {
+	case sechat.EventMessagePosted:
+		// Messages from Matrix are posted by the bridge's chat account; once
+		// recorded, handlePosted ignores them
+		if e.UserID == b.conn.UserID() {
+			b.waitForSend(e)
+		}
+		err = b.handlePosted(roomID, e)
+	case sechat.EventMessageEdited:
+		if !b.consumeEcho(e.EventType, e.MessageID) {
+			err = b.handleEdited(roomID, e)
+		}
+	case sechat.EventMessageDeleted:
+		if !b.consumeEcho(e.EventType, e.MessageID) {
+			err = b.handleDeleted(e)
+		}
+	case sechat.EventMessageStarred:
+		err = b.handleStarred(roomID, e)
+	}
+	if err != nil {
+		b.log.Error(err)
+	}
+}
+
+// handlePosted sends a new message to Matrix as the author's ghost.
+func (b *Bridge) handlePosted(roomID string, e *sechat.Event) error {
+	b.mutex.Lock()
+	_, exists := b.messages[e.MessageID]
+	parent := b.messages[e.ParentID]
+	b.mutex.Unlock()
+	if exists {
+		return nil
+	}
+	userID, err := b.ensureGhost(e.UserID, e.UserName, roomID)
+	if err != nil {
+		return err
+	}
+	content := messageContent(e)
+	if parent != nil {
+		content["m.relates_to"] = map[string]interface{}{
+			"m.in_reply_to": map[string]string{"event_id": parent.eventID},
+		}
+	}
+	eventID, err := b.matrix.send(roomID, userID, "m.room.message", content)
+	if err != nil {
+		return err
+	}
+	b.mutex.Lock()
+	b.messages[e.MessageID] = &matrixMessage{
+		roomID:  roomID,
+		eventID: eventID,
+		userID:  userID,
+	}
+	b.events[eventID] = e.MessageID
+	b.mutex.Unlock()
+	return nil
+}
+
+// handleEdited replaces the content of a message that was sent earlier.
+func (b *Bridge) handleEdited(roomID string, e *sechat.Event) error {
+	b.mutex.Lock()
+	m, exists := b.messages[e.MessageID]
+	b.mutex.Unlock()
+	if !exists {
+		return b.handlePosted(roomID, e)
+	}
+	// Only the sender can replace a Matrix event, which isn't possible for
+	// messages from real Matrix users, so the bridge's user replies instead
+	if !b.isBridged(m.userID) {
+		content := messageContent(e)
+		content["msgtype"] = "m.notice"
+		content["body"] = fmt.Sprintf("(edited in chat) %s", content["body"])
+		delete(content, "format")
+		delete(content, "formatted_body")
+		content["m.relates_to"] = map[string]interface{}{
+			"m.in_reply_to": map[string]string{"event_id": m.eventID},
+		}
+		_, err := b.matrix.send(roomID, "", "m.room.message", content)
+		return err
+	}
+	var (
+		newContent = messageContent(e)
+		content    = messageContent(e)
+	)
+	content["body"] = fmt.Sprintf("* %s", content["body"])
+	content["m.new_content"] = newContent
+	content["m.relates_to"] = map[string]string{
+		"rel_type": "m.replace",
+		"event_id": m.eventID,
+	}
+	_, err := b.matrix.send(roomID, m.userID, "m.room.message", content)
+	return err
+}
+
+// handleDeleted redacts a message that was sent earlier.
+func (b *Bridge) handleDeleted(e *sechat.Event) error {
+	b.mutex.Lock()
+	m, exists := b.messages[e.MessageID]
+	b.mutex.Unlock()
+	if !exists {
+		return nil
+	}
+	// Events from real Matrix users are redacted by the bridge's user
+	userID := m.userID
+	if !b.isBridged(userID) {
+		userID = ""
+	}
+	return b.matrix.redact(m.roomID, userID, m.eventID)
+}
+
+// handleStarred adds a reaction from the bridge's user when a message is first
+// starred and removes it when the last star is removed. No reaction is added
+// if Matrix users have already reacted, since the star is theirs.
+func (b *Bridge) handleStarred(roomID string, e *sechat.Event) error {
+	b.mutex.Lock()
+	m, exists := b.messages[e.MessageID]
+	reaction, reacted := b.stars[e.MessageID]
+	fromMatrix := len(b.reactions[e.MessageID]) != 0
+	b.mutex.Unlock()
+	if !exists {
+		return nil
+	}
+	switch {
+	case e.MessageStars > 0 && !reacted && !fromMatrix:
+		eventID, err := b.matrix.send(roomID, "", "m.reaction", map[string]interface{}{
+			"m.relates_to": map[string]string{
+				"rel_type": "m.annotation",
+				"event_id": m.eventID,
+				"key":      starKey,
+			},
+		})
+		if err != nil {
+			return err
+		}
+		b.mutex.Lock()
+		b.stars[e.MessageID] = eventID
+		b.mutex.Unlock()
+	case e.MessageStars == 0 && reacted:
+		if err := b.matrix.redact(roomID, "", reaction); err != nil {
+			return err
+		}
+		b.mutex.Lock()
+		delete(b.stars, e.MessageID)
+		b.mutex.Unlock()
+	}
+	return nil
+}
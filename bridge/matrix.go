@@ -0,0 +1,240 @@
+package bridge
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// matrixError is returned by the homeserver when a request fails.
+type matrixError struct {
+	Status  int
+	ErrCode string `json:"errcode"`
+	Message string `json:"error"`
+}
+
+// Error returns a description of the error.
+func (m *matrixError) Error() string {
+	return fmt.Sprintf("%d %s: %s", m.Status, m.ErrCode, m.Message)
+}
+
+// matrixClient makes requests to the homeserver's client-server API using the
+// application service's token. Requests can be made on behalf of any user in
+// the application service's namespace.
+type matrixClient struct {
+	client        *http.Client
+	homeserverURL string
+	token         string
+	txnID         int64
+}
+
+// newMatrixClient creates a client for the homeserver.
+func newMatrixClient(homeserverURL, token string) *matrixClient {
+	return &matrixClient{
+		client:        &http.Client{Timeout: 60 * time.Second},
+		homeserverURL: strings.TrimRight(homeserverURL, "/"),
+		token:         token,
+	}
+}
+
+// nextTxnID returns a unique transaction ID for sending events.
+func (m *matrixClient) nextTxnID() string {
+	return fmt.Sprintf("sechat.%d.%d", time.Now().UnixNano(), atomic.AddInt64(&m.txnID, 1))
+}
+
+// do makes a request as the specified user (or the application service's own
+// user if empty) and decodes the JSON response into v (if non-nil).
+func (m *matrixClient) do(method, path, userID string, body, v interface{}) error {
+	u := m.homeserverURL + path
+	if len(userID) != 0 {
+		sep := "?"
+		if strings.Contains(u, "?") {
+			sep = "&"
+		}
+		u += sep + url.Values{"user_id": {userID}}.Encode()
+	}
+	var r io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(b)
+	}
+	req, err := http.NewRequest(method, u, r)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+m.token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	res, err := m.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode >= 400 {
+		e := &matrixError{Status: res.StatusCode}
+		json.NewDecoder(res.Body).Decode(e)
+		return e
+	}
+	if v != nil {
+		return json.NewDecoder(res.Body).Decode(v)
+	}
+	return nil
+}
+
+// register creates a user in the application service's namespace. It is not
+// an error if the user already exists.
+func (m *matrixClient) register(localpart string) error {
+	err := m.do(
+		http.MethodPost,
+		"/_matrix/client/v3/register",
+		"",
+		map[string]string{
+			"type":     "m.login.application_service",
+			"username": localpart,
+		},
+		nil,
+	)
+	var mErr *matrixError
+	if errors.As(err, &mErr) && mErr.ErrCode == "M_USER_IN_USE" {
+		return nil
+	}
+	return err
+}
+
+// setDisplayName changes the display name of a user.
+func (m *matrixClient) setDisplayName(userID, name string) error {
+	return m.do(
+		http.MethodPut,
+		fmt.Sprintf("/_matrix/client/v3/profile/%s/displayname", url.PathEscape(userID)),
+		userID,
+		map[string]string{"displayname": name},
+		nil,
+	)
+}
+
+// createRoom creates a public room with the specified alias and name and
+// returns its ID.
+func (m *matrixClient) createRoom(alias, name, topic string) (string, error) {
+	var v struct {
+		RoomID string `json:"room_id"`
+	}
+	if err := m.do(
+		http.MethodPost,
+		"/_matrix/client/v3/createRoom",
+		"",
+		map[string]interface{}{
+			"room_alias_name": alias,
+			"name":            name,
+			"topic":           topic,
+			"preset":          "public_chat",
+			"visibility":      "private",
+		},
+		&v,
+	); err != nil {
+		return "", err
+	}
+	return v.RoomID, nil
+}
+
+// invite invites a user to a room.
+func (m *matrixClient) invite(roomID, userID string) error {
+	return m.do(
+		http.MethodPost,
+		fmt.Sprintf("/_matrix/client/v3/rooms/%s/invite", url.PathEscape(roomID)),
+		"",
+		map[string]string{"user_id": userID},
+		nil,
+	)
+}
+
+// join joins a room as the specified user.
+func (m *matrixClient) join(roomID, userID string) error {
+	return m.do(
+		http.MethodPost,
+		fmt.Sprintf("/_matrix/client/v3/join/%s", url.PathEscape(roomID)),
+		userID,
+		map[string]string{},
+		nil,
+	)
+}
+
+// send sends an event to a room as the specified user and returns its ID.
+func (m *matrixClient) send(roomID, userID, eventType string, content interface{}) (string, error) {
+	var v struct {
+		EventID string `json:"event_id"`
+	}
+	if err := m.do(
+		http.MethodPut,
+		fmt.Sprintf(
+			"/_matrix/client/v3/rooms/%s/send/%s/%s",
+			url.PathEscape(roomID),
+			url.PathEscape(eventType),
+			m.nextTxnID(),
+		),
+		userID,
+		content,
+		&v,
+	); err != nil {
+		return "", err
+	}
+	return v.EventID, nil
+}
+
+// redact removes an event as the specified user.
+func (m *matrixClient) redact(roomID, userID, eventID string) error {
+	return m.do(
+		http.MethodPut,
+		fmt.Sprintf(
+			"/_matrix/client/v3/rooms/%s/redact/%s/%s",
+			url.PathEscape(roomID),
+			url.PathEscape(eventID),
+			m.nextTxnID(),
+		),
+		userID,
+		map[string]string{},
+		nil,
+	)
+}
+
+// download retrieves the content at an mxc:// URI. The caller must close the
+// returned body.
+func (m *matrixClient) download(mxc string) (io.ReadCloser, error) {
+	u, err := url.Parse(mxc)
+	if err != nil || u.Scheme != "mxc" {
+		return nil, fmt.Errorf("invalid media URI %q", mxc)
+	}
+	req, err := http.NewRequest(
+		http.MethodGet,
+		fmt.Sprintf(
+			"%s/_matrix/client/v1/media/download/%s%s",
+			m.homeserverURL,
+			u.Host,
+			u.Path,
+		),
+		nil,
+	)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+m.token)
+	res, err := m.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode >= 400 {
+		res.Body.Close()
+		return nil, &matrixError{Status: res.StatusCode}
+	}
+	return res.Body, nil
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/nathan-osman/go-sechat"
+)
+
+// day contains everything needed to write the archive for a single day.
+type day struct {
+	Room     int
+	RoomName string
+	Date     time.Time
+	Events   []*sechat.Event
+	Users    map[int]*sechat.User
+	Avatars  map[int]string
+}
+
+// archiver retrieves transcripts along with the users who appear in them.
+// User information and avatars are cached across days.
+type archiver struct {
+	conn    *sechat.Conn
+	writer  writer
+	client  *http.Client
+	users   map[int]*sechat.User
+	avatars map[int]string
+}
+
+// newArchiver creates an archiver that writes using the provided writer.
+func newArchiver(c *sechat.Conn, w writer) *archiver {
+	return &archiver{
+		conn:    c,
+		writer:  w,
+		client:  &http.Client{Timeout: 30 * time.Second},
+		users:   map[int]*sechat.User{},
+		avatars: map[int]string{},
+	}
+}
+
+// loadUsers retrieves information for users that haven't been seen before.
+// Users that no longer exist (and feeds) may not be returned.
+func (a *archiver) loadUsers(room int, events []*sechat.Event) error {
+	ids := []int{}
+	seen := map[int]struct{}{}
+	for _, e := range events {
+		if _, exists := a.users[e.UserID]; exists {
+			continue
+		}
+		if _, exists := seen[e.UserID]; exists || e.UserID <= 0 {
+			continue
+		}
+		seen[e.UserID] = struct{}{}
+		ids = append(ids, e.UserID)
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	users, err := a.conn.Users(room, ids)
+	if err != nil {
+		return err
+	}
+	for _, u := range users {
+		a.users[u.ID] = u
+	}
+	return nil
+}
+
+// avatarURL determines the URL of a user's avatar from their email hash.
+// Custom avatars are indicated by a leading "!" followed by the URL.
+func avatarURL(u *sechat.User) string {
+	if strings.HasPrefix(u.EmailHash, "!") {
+		url := strings.TrimPrefix(u.EmailHash, "!")
+		if strings.HasPrefix(url, "//") {
+			url = "https:" + url
+		}
+		return url
+	}
+	return fmt.Sprintf(
+		"https://www.gravatar.com/avatar/%s?s=32&d=identicon&r=PG",
+		u.EmailHash,
+	)
+}
+
+// avatar returns a data URI containing the user's avatar so that archives
+// are self-contained. An empty string is returned if it cannot be retrieved.
+func (a *archiver) avatar(u *sechat.User) string {
+	if v, exists := a.avatars[u.ID]; exists {
+		return v
+	}
+	a.avatars[u.ID] = ""
+	res, err := a.client.Get(avatarURL(u))
+	if err != nil {
+		return ""
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return ""
+	}
+	b, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return ""
+	}
+	a.avatars[u.ID] = fmt.Sprintf(
+		"data:%s;base64,%s",
+		http.DetectContentType(b),
+		base64.StdEncoding.EncodeToString(b),
+	)
+	return a.avatars[u.ID]
+}
+
+// archive retrieves the transcript for a day and writes it to the specified
+// path. A temporary file is used so that incomplete archives are never left
+// behind.
+func (a *archiver) archive(room int, date time.Time, path string) error {
+	events, err := a.conn.Transcript(room, date)
+	if err != nil {
+		return err
+	}
+	d := &day{
+		Room:    room,
+		Date:    date,
+		Events:  events,
+		Users:   a.users,
+		Avatars: map[int]string{},
+	}
+	if a.writer.needsUsers() {
+		if err := a.loadUsers(room, events); err != nil {
+			return err
+		}
+		for _, e := range events {
+			if u, exists := a.users[e.UserID]; exists {
+				d.Avatars[e.UserID] = a.avatar(u)
+			}
+		}
+	}
+	for _, e := range events {
+		if len(e.RoomName) != 0 {
+			d.RoomName = e.RoomName
+			break
+		}
+	}
+	f, err := ioutil.TempFile(filepath.Dir(path), ".archive-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if err := a.writer.write(f, d); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
@@ -0,0 +1,121 @@
+// Command sechat-archive saves the transcripts of chat rooms to disk.
+//
+// One file is written for each room and day. Days that have already been
+// archived are skipped, so an interrupted run can simply be repeated (or the
+// command run nightly with the same arguments) to resume where it left off.
+// The current day (in UTC) is incomplete, so it is archived again each time.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/nathan-osman/go-sechat"
+	"github.com/nathan-osman/go-sechat/internal/config"
+	"github.com/sirupsen/logrus"
+)
+
+const dateFormat = "2006-01-02"
+
+var errConnect = errors.New("unable to connect to chat")
+
+func main() {
+	var (
+		configPath = flag.String("config", config.DefaultPath(), "path to config file")
+		from       = flag.String("from", "", "first day to archive (YYYY-MM-DD, default: yesterday)")
+		to         = flag.String("to", "", "last day to archive (YYYY-MM-DD, default: yesterday)")
+		formatName = flag.String("format", "jsonl", "output format (jsonl, html, or markdown)")
+		out        = flag.String("out", ".", "directory to write the archives to")
+	)
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [options] <room>...\n\nOptions:\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	w, ok := writers[*formatName]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown format %q\n", *formatName)
+		os.Exit(2)
+	}
+	rooms := []int{}
+	for _, a := range flag.Args() {
+		r, err := strconv.Atoi(a)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid room %q\n", a)
+			os.Exit(2)
+		}
+		rooms = append(rooms, r)
+	}
+	yesterday := time.Now().UTC().AddDate(0, 0, -1).Format(dateFormat)
+	if len(*from) == 0 {
+		*from = yesterday
+	}
+	if len(*to) == 0 {
+		*to = yesterday
+	}
+	start, err := time.Parse(dateFormat, *from)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid date %q\n", *from)
+		os.Exit(2)
+	}
+	end, err := time.Parse(dateFormat, *to)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid date %q\n", *to)
+		os.Exit(2)
+	}
+	if err := run(*configPath, rooms, start, end, w, *out); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+}
+
+// run connects to chat and archives each day in the range for each room.
+func run(configPath string, rooms []int, start, end time.Time, w writer, out string) error {
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		return err
+	}
+	logrus.SetLevel(logrus.WarnLevel)
+	c, err := sechat.New(cfg.Email, cfg.Password, rooms[0])
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if !c.WaitForConnected() {
+		return errConnect
+	}
+	var (
+		a     = newArchiver(c, w)
+		now   = time.Now().UTC()
+		today = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	)
+	for _, r := range rooms {
+		dir := filepath.Join(out, strconv.Itoa(r))
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+		for d := start; !d.After(end); d = d.AddDate(0, 0, 1) {
+			path := filepath.Join(dir, d.Format(dateFormat)+w.extension())
+			// Only days that have ended can be complete
+			if d.Before(today) {
+				if _, err := os.Stat(path); err == nil {
+					continue
+				}
+			}
+			fmt.Printf("archiving room %d for %s\n", r, d.Format(dateFormat))
+			if err := a.archive(r, d, path); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/nathan-osman/go-sechat"
+)
+
+// writer writes the archive for a single day in a particular format.
+type writer interface {
+	extension() string
+	needsUsers() bool
+	write(w io.Writer, d *day) error
+}
+
+// writers maps format names to their implementation.
+var writers = map[string]writer{
+	"jsonl":    jsonWriter{},
+	"html":     htmlWriter{},
+	"markdown": markdownWriter{},
+}
+
+// jsonWriter writes each event as a single line of JSON.
+type jsonWriter struct{}
+
+func (jsonWriter) extension() string { return ".jsonl" }
+func (jsonWriter) needsUsers() bool  { return false }
+
+func (jsonWriter) write(w io.Writer, d *day) error {
+	enc := json.NewEncoder(w)
+	for _, e := range d.Events {
+		if err := enc.Encode(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// markdownWriter writes the messages as a Markdown document.
+type markdownWriter struct{}
+
+func (markdownWriter) extension() string { return ".md" }
+func (markdownWriter) needsUsers() bool  { return false }
+
+func (markdownWriter) write(w io.Writer, d *day) error {
+	title := d.RoomName
+	if len(title) == 0 {
+		title = fmt.Sprintf("Room %d", d.Room)
+	}
+	if _, err := fmt.Fprintf(
+		w,
+		"# %s — %s\n\n",
+		title,
+		d.Date.Format(dateFormat),
+	); err != nil {
+		return err
+	}
+	for _, e := range d.Events {
+		text := e.Markdown
+		// Keep multi-line messages within the list item
+		text = strings.Replace(text, "\n", "\n  ", -1)
+		if _, err := fmt.Fprintf(
+			w,
+			"- **%s** (%s): %s\n",
+			e.UserName,
+			time.Unix(int64(e.TimeStamp), 0).UTC().Format("15:04"),
+			text,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// htmlTemplate renders a self-contained page for a single day.
+var htmlTemplate = template.Must(template.New("").Funcs(template.FuncMap{
+	"time": func(e *sechat.Event) string {
+		return time.Unix(int64(e.TimeStamp), 0).UTC().Format("15:04")
+	},
+	"content": func(e *sechat.Event) template.HTML {
+		// Content is generated by the chat server
+		return template.HTML(e.Content)
+	},
+	"avatar": func(d *day, e *sechat.Event) template.URL {
+		return template.URL(d.Avatars[e.UserID])
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{if .RoomName}}{{.RoomName}}{{else}}Room {{.Room}}{{end}} — {{.Date.Format "2006-01-02"}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em auto; max-width: 60em; }
+.message { display: flex; margin: 0.5em 0; }
+.avatar { width: 32px; height: 32px; margin-right: 0.75em; flex-shrink: 0; }
+.meta { color: #777; font-size: 0.85em; }
+.stars { color: #c90; }
+pre { white-space: pre-wrap; }
+img { max-width: 100%; }
+</style>
+</head>
+<body>
+<h1>{{if .RoomName}}{{.RoomName}}{{else}}Room {{.Room}}{{end}}</h1>
+<h2>{{.Date.Format "Monday, January 2, 2006"}}</h2>
+{{$d := .}}{{range .Events}}<div class="message" id="message-{{.MessageID}}">
+{{with avatar $d .}}<img class="avatar" src="{{.}}" alt="">{{else}}<div class="avatar"></div>{{end}}
+<div>
+<div class="meta"><strong>{{.UserName}}</strong> {{time .}}{{if .ParentID}} · reply to <a href="#message-{{.ParentID}}">{{.ParentID}}</a>{{end}}{{if .MessageStars}} <span class="stars">★ {{.MessageStars}}</span>{{end}}</div>
+<div class="content">{{content .}}</div>
+</div>
+</div>
+{{end}}</body>
+</html>
+`))
+
+// htmlWriter writes the messages as a self-contained HTML page with avatars
+// embedded as data URIs.
+type htmlWriter struct{}
+
+func (htmlWriter) extension() string { return ".html" }
+func (htmlWriter) needsUsers() bool  { return true }
+
+func (htmlWriter) write(w io.Writer, d *day) error {
+	return htmlTemplate.Execute(w, d)
+}
@@ -0,0 +1,89 @@
+// Command sechat-gateway runs an HTTP server that allows other programs (such
+// as CI systems) to post to the Stack Exchange chat network without logging in
+// themselves. All requests share a single chat connection.
+//
+// Requests must include an API token in the Authorization header:
+//
+//     Authorization: Bearer <token>
+//
+// Tokens are read from a JSON file containing a list of tokens, the rooms each
+// one may access, and whether it may upload images:
+//
+//     [
+//         {"name": "ci", "token": "abc123", "rooms": [201, 202]},
+//         {"name": "admin", "token": "def456", "all_rooms": true, "images": true}
+//     ]
+//
+// The following endpoints are provided:
+//
+//     POST /rooms/{id}/messages    post a message ({"text": "...", "reply_to": 0})
+//     GET  /rooms/{id}/users       list the users in a room
+//     POST /messages/{id}/star     toggle the star on a message
+//     POST /images                 upload the image in the request body
+//
+// Chat toggles stars, so starring a message that the chat account has already
+// starred removes the star. Clients should not retry star requests that may
+// have succeeded.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+
+	"github.com/nathan-osman/go-sechat"
+	"github.com/nathan-osman/go-sechat/internal/config"
+	"github.com/sirupsen/logrus"
+)
+
+var errConnect = errors.New("unable to connect to chat")
+
+func main() {
+	var (
+		configPath = flag.String("config", config.DefaultPath(), "path to config file")
+		listen     = flag.String("listen", "127.0.0.1:8080", "address to listen on")
+		tokensPath = flag.String("tokens", "tokens.json", "path to API token file")
+		room       = flag.Int("room", 1, "room used for the initial connection")
+	)
+	flag.Parse()
+	if err := run(*configPath, *listen, *tokensPath, *room); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+}
+
+// run connects to chat and serves API requests until interrupted.
+func run(configPath, listen, tokensPath string, room int) error {
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		return err
+	}
+	t, err := loadTokens(tokensPath)
+	if err != nil {
+		return err
+	}
+	c, err := sechat.New(cfg.Email, cfg.Password, room)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if !c.WaitForConnected() {
+		return errConnect
+	}
+	l, err := net.Listen("tcp", listen)
+	if err != nil {
+		return err
+	}
+	srv := &http.Server{Handler: newServer(c, t)}
+	go srv.Serve(l)
+	defer srv.Close()
+	logrus.Infof("listening on %s", l.Addr())
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt)
+	<-sigCh
+	return nil
+}
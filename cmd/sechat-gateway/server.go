@@ -0,0 +1,213 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/nathan-osman/go-sechat"
+	"github.com/nathan-osman/go-sechat/format"
+	"github.com/sirupsen/logrus"
+)
+
+// maxMessageBody limits the size of a message request.
+const maxMessageBody = 64 * 1024
+
+// server handles API requests using a shared chat connection.
+type server struct {
+	conn   *sechat.Conn
+	tokens map[string]*token
+	log    *logrus.Entry
+}
+
+// newServer creates a server for the connection and tokens.
+func newServer(c *sechat.Conn, tokens map[string]*token) *server {
+	return &server{
+		conn:   c,
+		tokens: tokens,
+		log:    logrus.WithField("context", "gateway"),
+	}
+}
+
+// writeJSON sends a JSON response.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError sends a JSON error response.
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
+
+// authenticate returns the token in the request's Authorization header, which
+// must use the Bearer scheme. Every token is compared (in constant time) so
+// that the response time doesn't reveal how much of a token matched.
+func (s *server) authenticate(r *http.Request) *token {
+	h := r.Header.Get("Authorization")
+	if !strings.HasPrefix(h, "Bearer ") {
+		return nil
+	}
+	var (
+		v     = []byte(strings.TrimPrefix(h, "Bearer "))
+		match *token
+	)
+	for k, t := range s.tokens {
+		if subtle.ConstantTimeCompare([]byte(k), v) == 1 {
+			match = t
+		}
+	}
+	return match
+}
+
+// ServeHTTP authenticates the request and dispatches it to the handler for
+// the endpoint.
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	t := s.authenticate(r)
+	if t == nil {
+		writeError(w, http.StatusUnauthorized, "invalid token")
+		return
+	}
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case len(parts) == 3 && parts[0] == "rooms" && parts[2] == "messages":
+		s.withID(w, r, http.MethodPost, parts[1], func(id int) {
+			s.postMessage(w, r, t, id)
+		})
+	case len(parts) == 3 && parts[0] == "rooms" && parts[2] == "users":
+		s.withID(w, r, http.MethodGet, parts[1], func(id int) {
+			s.roomUsers(w, t, id)
+		})
+	case len(parts) == 3 && parts[0] == "messages" && parts[2] == "star":
+		s.withID(w, r, http.MethodPost, parts[1], func(id int) {
+			s.starMessage(w, t, id)
+		})
+	case len(parts) == 1 && parts[0] == "images":
+		if r.Method != http.MethodPost {
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		s.uploadImage(w, r, t)
+	default:
+		writeError(w, http.StatusNotFound, "unknown endpoint")
+	}
+}
+
+// withID checks the method and parses the ID in the path before invoking fn.
+func (s *server) withID(w http.ResponseWriter, r *http.Request, method, v string, fn func(int)) {
+	if r.Method != method {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	id, err := strconv.Atoi(v)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid ID")
+		return
+	}
+	fn(id)
+}
+
+// chatError reports an error from the chat server. Throttling has already
+// been retried by the connection, so any error here is passed on.
+func (s *server) chatError(w http.ResponseWriter, err error) {
+	s.log.Error(err)
+	writeError(w, http.StatusBadGateway, err.Error())
+}
+
+// postMessage posts a message to a room. Long messages are split into
+// multiple messages, so the IDs of all of them are returned.
+func (s *server) postMessage(w http.ResponseWriter, r *http.Request, t *token, room int) {
+	if !t.canAccess(room) {
+		writeError(w, http.StatusForbidden, "token cannot access room")
+		return
+	}
+	var v struct {
+		Text    string `json:"text"`
+		ReplyTo int    `json:"reply_to"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxMessageBody)).Decode(&v); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	text := v.Text
+	if v.ReplyTo != 0 {
+		text = format.Reply(v.ReplyTo, text)
+	}
+	if err := format.Validate(text); err == format.ErrEmpty {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	ids, err := s.conn.SendLong(room, text)
+	if err != nil {
+		s.chatError(w, err)
+		return
+	}
+	s.log.Infof("%s posted %d message(s) in room %d", t.Name, len(ids), room)
+	writeJSON(w, http.StatusCreated, map[string][]int{"ids": ids})
+}
+
+// roomUsers lists the users in a room.
+func (s *server) roomUsers(w http.ResponseWriter, t *token, room int) {
+	if !t.canAccess(room) {
+		writeError(w, http.StatusForbidden, "token cannot access room")
+		return
+	}
+	users, err := s.conn.UsersInRoom(room)
+	if err != nil {
+		s.chatError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, users)
+}
+
+// starMessage toggles the star on a message. The message is looked up first
+// to ensure that it is in a room the token can access.
+func (s *server) starMessage(w http.ResponseWriter, t *token, message int) {
+	e, err := s.conn.Message(message)
+	if err != nil {
+		if err == sechat.ErrMessageNotFound {
+			writeError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		s.chatError(w, err)
+		return
+	}
+	if !t.canAccess(e.RoomID) {
+		writeError(w, http.StatusForbidden, "token cannot access room")
+		return
+	}
+	if err := s.conn.Star(message); err != nil {
+		s.chatError(w, err)
+		return
+	}
+	s.log.Infof("%s toggled star on message %d", t.Name, message)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// uploadImage uploads the request body as an image. Uploads aren't tied to a
+// room, so the token needs explicit permission.
+func (s *server) uploadImage(w http.ResponseWriter, r *http.Request, t *token) {
+	if !t.Images {
+		writeError(w, http.StatusForbidden, "token cannot upload images")
+		return
+	}
+	u, err := s.conn.UploadImage(r.Body, &sechat.UploadOptions{
+		Filename: r.URL.Query().Get("filename"),
+		Size:     r.ContentLength,
+	})
+	if err != nil {
+		switch err {
+		case sechat.ErrImageTooLarge:
+			writeError(w, http.StatusRequestEntityTooLarge, err.Error())
+		case sechat.ErrImageFormat:
+			writeError(w, http.StatusUnsupportedMediaType, err.Error())
+		default:
+			s.chatError(w, err)
+		}
+		return
+	}
+	writeJSON(w, http.StatusCreated, map[string]string{"url": u})
+}
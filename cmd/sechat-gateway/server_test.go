@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/nathan-osman/go-sechat"
+)
+
+func TestServerErrors(t *testing.T) {
+	s := newServer(nil, map[string]*token{
+		"ci": {Name: "ci", Token: "ci", Rooms: []int{201}},
+	})
+	for _, test := range []struct {
+		method string
+		path   string
+		token  string
+		body   string
+		status int
+	}{
+		{http.MethodPost, "/rooms/201/messages", "", `{"text": "a"}`, http.StatusUnauthorized},
+		{http.MethodPost, "/rooms/201/messages", "c", `{"text": "a"}`, http.StatusUnauthorized},
+		{http.MethodPost, "/rooms/201/messages", "ci2", `{"text": "a"}`, http.StatusUnauthorized},
+		{http.MethodPost, "/rooms/201/messages", "wrong", `{"text": "a"}`, http.StatusUnauthorized},
+		{http.MethodPost, "/rooms/202/messages", "ci", `{"text": "a"}`, http.StatusForbidden},
+		{http.MethodPost, "/rooms/201/messages", "ci", `{"text": " "}`, http.StatusBadRequest},
+		{http.MethodPost, "/rooms/201/messages", "ci", `{`, http.StatusBadRequest},
+		{http.MethodGet, "/rooms/201/messages", "ci", "", http.StatusMethodNotAllowed},
+		{http.MethodPost, "/rooms/abc/messages", "ci", "", http.StatusBadRequest},
+		{http.MethodGet, "/rooms/202/users", "ci", "", http.StatusForbidden},
+		{http.MethodPost, "/images", "ci", "", http.StatusForbidden},
+		{http.MethodGet, "/images", "ci", "", http.StatusMethodNotAllowed},
+		{http.MethodGet, "/unknown", "ci", "", http.StatusNotFound},
+	} {
+		r := httptest.NewRequest(test.method, test.path, strings.NewReader(test.body))
+		if len(test.token) != 0 {
+			r.Header.Set("Authorization", "Bearer "+test.token)
+		}
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, r)
+		if w.Code != test.status {
+			t.Fatalf("%s %s: %d != %d", test.method, test.path, w.Code, test.status)
+		}
+	}
+	// The token must use the Bearer scheme
+	for _, header := range []string{"ci", "Basic ci", "bearer ci", "Bearer"} {
+		r := httptest.NewRequest(http.MethodGet, "/rooms/201/users", nil)
+		r.Header.Set("Authorization", header)
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, r)
+		if w.Code != http.StatusUnauthorized {
+			t.Fatalf("%q: %d != %d", header, w.Code, http.StatusUnauthorized)
+		}
+	}
+}
+
+// newTestServer creates a server backed by a fake chat server. Room 201 has
+// two users, message 5 is in room 201, message 6 is in room 202, and message
+// 7 doesn't exist.
+func newTestServer(t *testing.T) (*server, *[]string) {
+	var (
+		requests = &[]string{}
+		nextID   = 100
+	)
+	c := sechat.NewTestConn(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*requests = append(*requests, r.URL.Path)
+		switch r.URL.Path {
+		case "/chats/201/messages/new":
+			if v := r.FormValue("text"); v != ":3 a" {
+				t.Errorf("%q != :3 a", v)
+			}
+			nextID++
+			fmt.Fprintf(w, `{"id":%d}`, nextID)
+		case "/rooms/201":
+			fmt.Fprint(w, `<script>$(function() {
+				CHAT.RoomUsers.initPresent([{id: 1, name: "Alice"}, {id: 2, name: "Bob"}]);
+			});</script>`)
+		case "/transcript/message/5", "/transcript/message/6":
+			id := strings.TrimPrefix(r.URL.Path, "/transcript/message/")
+			room := map[string]int{"5": 201, "6": 202}[id]
+			fmt.Fprintf(
+				w,
+				`<div class="room-name"><a href="/rooms/%d/test">Test</a></div>
+				<div class="monologue user-1"><div class="messages">
+				<div class="message" id="message-%s"><div class="content">a</div></div>
+				</div></div>`,
+				room, id,
+			)
+		case "/transcript/message/7":
+			fmt.Fprint(w, `<div class="room-name"></div>`)
+		case "/messages/5/star":
+		case "/upload/image":
+			fmt.Fprint(w, `<script>var result = 'https://i.stack.imgur.com/a.png';</script>`)
+		default:
+			http.NotFound(w, r)
+		}
+	}), 1, "gateway")
+	return newServer(c, map[string]*token{
+		"ci": {Name: "ci", Token: "ci", Rooms: []int{201}, Images: true},
+	}), requests
+}
+
+// request makes an authenticated request and returns the response.
+func request(s *server, method, path string, body []byte) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, path, bytes.NewReader(body))
+	r.Header.Set("Authorization", "Bearer ci")
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	return w
+}
+
+func TestServer(t *testing.T) {
+	s, requests := newTestServer(t)
+	w := request(s, http.MethodPost, "/rooms/201/messages", []byte(`{"text": "a", "reply_to": 3}`))
+	var ids struct {
+		IDs []int `json:"ids"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&ids); err != nil || w.Code != http.StatusCreated {
+		t.Fatalf("%d, %v", w.Code, err)
+	}
+	if !reflect.DeepEqual(ids.IDs, []int{101}) {
+		t.Fatalf("%v != [101]", ids.IDs)
+	}
+	w = request(s, http.MethodGet, "/rooms/201/users", nil)
+	var users []*sechat.User
+	if err := json.NewDecoder(w.Body).Decode(&users); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("%d, %v", w.Code, err)
+	}
+	if len(users) != 2 || users[0].Name != "Alice" || users[1].ID != 2 {
+		t.Fatalf("%+v", users)
+	}
+	for _, test := range []struct {
+		message int
+		status  int
+	}{
+		{5, http.StatusNoContent},
+		{6, http.StatusForbidden},
+		{7, http.StatusNotFound},
+	} {
+		if w := request(s, http.MethodPost, fmt.Sprintf("/messages/%d/star", test.message), nil); w.Code != test.status {
+			t.Fatalf("%d: %d != %d", test.message, w.Code, test.status)
+		}
+	}
+	w = request(s, http.MethodPost, "/images?filename=a.png", []byte("\x89PNG\r\n\x1a\n"))
+	var image struct {
+		URL string `json:"url"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&image); err != nil || w.Code != http.StatusCreated {
+		t.Fatalf("%d, %v", w.Code, err)
+	}
+	if image.URL != "https://i.stack.imgur.com/a.png" {
+		t.Fatalf("%q", image.URL)
+	}
+	if w := request(s, http.MethodPost, "/images", []byte("not an image")); w.Code != http.StatusUnsupportedMediaType {
+		t.Fatalf("%d != %d", w.Code, http.StatusUnsupportedMediaType)
+	}
+	// The message in room 202 must not have been starred
+	expected := []string{
+		"/chats/201/messages/new",
+		"/rooms/201",
+		"/transcript/message/5",
+		"/messages/5/star",
+		"/transcript/message/6",
+		"/transcript/message/7",
+		"/upload/image",
+	}
+	if !reflect.DeepEqual(*requests, expected) {
+		t.Fatalf("%v != %v", *requests, expected)
+	}
+}
+
+func TestCanAccess(t *testing.T) {
+	for _, test := range []struct {
+		token  *token
+		room   int
+		output bool
+	}{
+		{&token{Rooms: []int{201}}, 201, true},
+		{&token{Rooms: []int{201}}, 202, false},
+		{&token{}, 201, false},
+		{&token{AllRooms: true}, 201, true},
+	} {
+		if v := test.token.canAccess(test.room); v != test.output {
+			t.Fatalf("%+v, %d: %v != %v", test.token, test.room, v, test.output)
+		}
+	}
+}
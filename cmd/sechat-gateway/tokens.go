@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+)
+
+var errNoTokens = errors.New("no API tokens defined")
+
+// token is an API token, the rooms it grants access to, and whether it may be
+// used to upload images.
+type token struct {
+	Name     string `json:"name"`
+	Token    string `json:"token"`
+	Rooms    []int  `json:"rooms"`
+	AllRooms bool   `json:"all_rooms"`
+	Images   bool   `json:"images"`
+}
+
+// canAccess determines if the token may be used with the specified room.
+func (t *token) canAccess(room int) bool {
+	if t.AllRooms {
+		return true
+	}
+	for _, r := range t.Rooms {
+		if r == room {
+			return true
+		}
+	}
+	return false
+}
+
+// loadTokens reads the list of tokens from a file and indexes them by value.
+func loadTokens(path string) (map[string]*token, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var l []*token
+	if err := json.NewDecoder(f).Decode(&l); err != nil {
+		return nil, err
+	}
+	tokens := map[string]*token{}
+	for _, t := range l {
+		if len(t.Token) == 0 {
+			continue
+		}
+		tokens[t.Token] = t
+	}
+	if len(tokens) == 0 {
+		return nil, errNoTokens
+	}
+	return tokens, nil
+}
@@ -0,0 +1,362 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nathan-osman/go-sechat/format"
+)
+
+// writeTimeout is the longest that a line may take to be written to a client.
+// Clients that can't keep up are disconnected so that they don't hold up the
+// others. It is a variable so that tests can reduce it.
+var writeTimeout = 10 * time.Second
+
+// addressRegexp matches the IRC convention of addressing a user by starting a
+// message with their nickname and a colon.
+var addressRegexp = regexp.MustCompile(`^([^\s:]+): `)
+
+// message is a parsed line from an IRC client.
+type message struct {
+	command string
+	params  []string
+}
+
+// parseMessage splits a line into its command and parameters. Any prefix is
+// ignored since clients should not send one.
+func parseMessage(line string) *message {
+	line = strings.TrimRight(line, "\r\n")
+	if strings.HasPrefix(line, ":") {
+		if i := strings.Index(line, " "); i != -1 {
+			line = line[i+1:]
+		} else {
+			return nil
+		}
+	}
+	var trailing *string
+	if i := strings.Index(line, " :"); i != -1 {
+		t := line[i+2:]
+		trailing = &t
+		line = line[:i]
+	}
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return nil
+	}
+	m := &message{
+		command: strings.ToUpper(fields[0]),
+		params:  fields[1:],
+	}
+	if trailing != nil {
+		m.params = append(m.params, *trailing)
+	}
+	return m
+}
+
+// client manages a connection from a single IRC client.
+type client struct {
+	server     *server
+	conn       net.Conn
+	mutex      sync.Mutex
+	nick       string
+	user       string
+	password   string
+	registered bool
+	rooms      map[int]struct{}
+}
+
+// newClient creates a client for the connection.
+func newClient(s *server, nc net.Conn) *client {
+	return &client{
+		server: s,
+		conn:   nc,
+		rooms:  map[int]struct{}{},
+	}
+}
+
+// send writes a line to the client, disconnecting it if the write fails or
+// does not complete in time.
+func (c *client) send(line string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	if _, err := fmt.Fprintf(c.conn, "%s\r\n", line); err != nil {
+		c.conn.Close()
+	}
+}
+
+// reply sends a numeric reply from the server.
+func (c *client) reply(numeric, text string) {
+	c.send(fmt.Sprintf(":%s %s %s %s", serverName, numeric, c.nickOrStar(), text))
+}
+
+// nickOrStar returns the client's nickname or "*" if it hasn't been set.
+func (c *client) nickOrStar() string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if len(c.nick) == 0 {
+		return "*"
+	}
+	return c.nick
+}
+
+// prefix returns the client's own hostmask.
+func (c *client) prefix() string {
+	return fmt.Sprintf("%s!%s@localhost", c.nickOrStar(), c.user)
+}
+
+// inRoom determines if the client has joined the channel for a room.
+func (c *client) inRoom(room int) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	_, ok := c.rooms[room]
+	return ok
+}
+
+// run reads commands from the client until it disconnects.
+func (c *client) run() {
+	defer c.conn.Close()
+	scanner := bufio.NewScanner(c.conn)
+	for scanner.Scan() {
+		m := parseMessage(scanner.Text())
+		if m == nil {
+			continue
+		}
+		if !c.handle(m) {
+			return
+		}
+	}
+}
+
+// close disconnects the client.
+func (c *client) close() {
+	c.conn.Close()
+}
+
+// handle processes a single command. False is returned if the client should
+// be disconnected.
+func (c *client) handle(m *message) bool {
+	switch m.command {
+	case "CAP":
+		if len(m.params) != 0 && strings.ToUpper(m.params[0]) == "LS" {
+			c.send(fmt.Sprintf(":%s CAP * LS :", serverName))
+		}
+		return true
+	case "PASS":
+		if len(m.params) != 0 {
+			c.password = m.params[0]
+		}
+		return true
+	case "NICK":
+		if len(m.params) == 0 {
+			c.reply("431", ":No nickname given")
+			return true
+		}
+		c.mutex.Lock()
+		c.nick = m.params[0]
+		c.mutex.Unlock()
+		return c.register()
+	case "USER":
+		if len(m.params) == 0 {
+			c.reply("461", "USER :Not enough parameters")
+			return true
+		}
+		c.user = m.params[0]
+		return c.register()
+	case "PING":
+		c.send(fmt.Sprintf(":%s PONG %s :%s", serverName, serverName, strings.Join(m.params, " ")))
+		return true
+	case "QUIT":
+		return false
+	}
+	if !c.registered {
+		c.reply("451", ":You have not registered")
+		return true
+	}
+	switch m.command {
+	case "JOIN":
+		c.join(m)
+	case "PART":
+		c.part(m)
+	case "PRIVMSG", "NOTICE":
+		c.privmsg(m)
+	case "NAMES":
+		if len(m.params) != 0 {
+			for _, name := range strings.Split(m.params[0], ",") {
+				c.names(name)
+			}
+		}
+	case "TOPIC":
+		if len(m.params) != 0 {
+			c.topic(m.params[0])
+		}
+	case "MODE":
+		if len(m.params) != 0 && parseChannel(m.params[0]) != 0 {
+			c.reply("324", fmt.Sprintf("%s +", m.params[0]))
+		}
+	case "WHO":
+		target := "*"
+		if len(m.params) != 0 {
+			target = m.params[0]
+		}
+		c.reply("315", fmt.Sprintf("%s :End of WHO list", target))
+	default:
+		c.reply("421", fmt.Sprintf("%s :Unknown command", m.command))
+	}
+	return true
+}
+
+// register completes registration once both NICK and USER have been received.
+func (c *client) register() bool {
+	if c.registered || len(c.user) == 0 || c.nickOrStar() == "*" {
+		return true
+	}
+	if c.server.password != "" && c.password != c.server.password {
+		c.reply("464", ":Password incorrect")
+		return false
+	}
+	c.registered = true
+	c.reply("001", ":Welcome to the Stack Exchange chat gateway")
+	c.reply("002", fmt.Sprintf(":Your host is %s", serverName))
+	c.reply("003", ":This server has no creation date")
+	c.reply("004", fmt.Sprintf("%s sechat o o", serverName))
+	c.reply("422", ":MOTD File is missing")
+	return true
+}
+
+// join joins each of the channels in the command.
+func (c *client) join(m *message) {
+	if len(m.params) == 0 {
+		c.reply("461", "JOIN :Not enough parameters")
+		return
+	}
+	for _, name := range strings.Split(m.params[0], ",") {
+		room := parseChannel(name)
+		if room == 0 {
+			c.reply("403", fmt.Sprintf("%s :No such channel", name))
+			continue
+		}
+		if err := c.server.conn.Join(room); err != nil {
+			c.reply("403", fmt.Sprintf("%s :%s", name, err))
+			continue
+		}
+		c.mutex.Lock()
+		c.rooms[room] = struct{}{}
+		c.mutex.Unlock()
+		c.send(fmt.Sprintf(":%s JOIN %s", c.prefix(), name))
+		c.topic(name)
+		c.names(name)
+	}
+}
+
+// part leaves each of the channels in the command. The room is only left in
+// chat once no clients remain in the channel.
+func (c *client) part(m *message) {
+	if len(m.params) == 0 {
+		c.reply("461", "PART :Not enough parameters")
+		return
+	}
+	for _, name := range strings.Split(m.params[0], ",") {
+		room := parseChannel(name)
+		if room == 0 || !c.inRoom(room) {
+			c.reply("442", fmt.Sprintf("%s :You're not on that channel", name))
+			continue
+		}
+		c.mutex.Lock()
+		delete(c.rooms, room)
+		c.mutex.Unlock()
+		c.send(fmt.Sprintf(":%s PART %s", c.prefix(), name))
+		if !c.server.inUse(room) {
+			if err := c.server.conn.Leave(room); err != nil {
+				c.server.log.Error(err)
+			}
+		}
+	}
+}
+
+// topic sends the name of the room as the channel topic.
+func (c *client) topic(name string) {
+	room := parseChannel(name)
+	if n := c.server.roomName(room); len(n) != 0 {
+		c.reply("332", fmt.Sprintf("%s :%s", name, n))
+	} else {
+		c.reply("331", fmt.Sprintf("%s :No topic is set", name))
+	}
+}
+
+// names sends the list of users in the room.
+func (c *client) names(name string) {
+	room := parseChannel(name)
+	if room != 0 {
+		users, err := c.server.conn.UsersInRoom(room)
+		if err == nil {
+			nicks := []string{}
+			for _, u := range users {
+				n := nick(u.Name)
+				if u.IsOwner || u.IsModerator {
+					n = "@" + n
+				}
+				nicks = append(nicks, n)
+				if len(nicks) == 20 {
+					c.reply("353", fmt.Sprintf("= %s :%s", name, strings.Join(nicks, " ")))
+					nicks = nicks[:0]
+				}
+			}
+			if len(nicks) != 0 {
+				c.reply("353", fmt.Sprintf("= %s :%s", name, strings.Join(nicks, " ")))
+			}
+		}
+	}
+	c.reply("366", fmt.Sprintf("%s :End of /NAMES list", name))
+}
+
+// privmsg posts a message to the room for a channel. CTCP ACTION messages are
+// posted in italics and "nick: " prefixes are converted to mentions.
+func (c *client) privmsg(m *message) {
+	if len(m.params) < 2 {
+		c.reply("412", ":No text to send")
+		return
+	}
+	room := parseChannel(m.params[0])
+	if room == 0 {
+		c.reply("401", fmt.Sprintf("%s :No such nick/channel", m.params[0]))
+		return
+	}
+	if !c.inRoom(room) {
+		c.reply("404", fmt.Sprintf("%s :Cannot send to channel", m.params[0]))
+		return
+	}
+	text := m.params[1]
+	if strings.HasPrefix(text, "\x01") && !strings.HasPrefix(text, "\x01ACTION ") {
+		return
+	}
+	// Other IRC clients in the channel share the chat account, so they
+	// would otherwise never see the message
+	c.server.broadcast(room, c, fmt.Sprintf(
+		":%s PRIVMSG %s :%s",
+		c.prefix(),
+		channel(room),
+		m.params[1],
+	))
+	if strings.HasPrefix(text, "\x01ACTION ") {
+		text = format.Italic(strings.TrimSuffix(strings.TrimPrefix(text, "\x01ACTION "), "\x01"))
+	} else {
+		text = addressRegexp.ReplaceAllString(text, "@$1 ")
+	}
+	// Posting may block while throttled
+	go func() {
+		if err := c.server.send(room, text); err != nil {
+			c.send(fmt.Sprintf(
+				":%s NOTICE %s :unable to send message: %s",
+				serverName,
+				m.params[0],
+				err,
+			))
+		}
+	}()
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseMessage(t *testing.T) {
+	for _, test := range []struct {
+		line   string
+		output *message
+	}{
+		{"", nil},
+		{":prefix", nil},
+		{"nick alice\r\n", &message{"NICK", []string{"alice"}}},
+		{"USER alice 0 * :Alice Smith", &message{"USER", []string{"alice", "0", "*", "Alice Smith"}}},
+		{"privmsg #201 :hello: world :)", &message{"PRIVMSG", []string{"#201", "hello: world :)"}}},
+		{":alice!a@localhost JOIN #1,#2", &message{"JOIN", []string{"#1,#2"}}},
+		{"PRIVMSG #201 :", &message{"PRIVMSG", []string{"#201", ""}}},
+	} {
+		if v := parseMessage(test.line); !reflect.DeepEqual(v, test.output) {
+			t.Fatalf("%q: %+v != %+v", test.line, v, test.output)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+// Command sechat-ircd runs a local IRC server that acts as a gateway to the
+// Stack Exchange chat network.
+//
+// Each chat room is available as a channel named after the room's ID (for
+// example, #201). Joining a channel joins the room, messages sent to the
+// channel are posted to the room, and messages posted in the room are relayed
+// to everyone in the channel. All IRC clients share a single chat connection.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+
+	"github.com/nathan-osman/go-sechat"
+	"github.com/nathan-osman/go-sechat/internal/config"
+	"github.com/sirupsen/logrus"
+)
+
+var errConnect = errors.New("unable to connect to chat")
+
+func main() {
+	var (
+		configPath = flag.String("config", config.DefaultPath(), "path to config file")
+		listen     = flag.String("listen", "127.0.0.1:6667", "address to listen on")
+		password   = flag.String("password", "", "password required from IRC clients")
+		room       = flag.Int("room", 1, "room used for the initial connection")
+	)
+	flag.Parse()
+	if err := run(*configPath, *listen, *password, *room); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+}
+
+// run connects to chat and serves IRC clients until interrupted.
+func run(configPath, listen, password string, room int) error {
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		return err
+	}
+	c, err := sechat.New(cfg.Email, cfg.Password, room)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if !c.WaitForConnected() {
+		return errConnect
+	}
+	l, err := net.Listen("tcp", listen)
+	if err != nil {
+		return err
+	}
+	s := newServer(c, l, password)
+	defer s.close()
+	logrus.Infof("listening on %s", l.Addr())
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt)
+	<-sigCh
+	return nil
+}
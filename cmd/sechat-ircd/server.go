@@ -0,0 +1,267 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nathan-osman/go-sechat"
+	"github.com/nathan-osman/go-sechat/format"
+	"github.com/sirupsen/logrus"
+)
+
+// serverName is used as the prefix for messages from the server.
+const serverName = "sechat-ircd"
+
+// echoTimeout is the longest that relaying a message from the chat account
+// waits for an in-progress send to finish so that it can tell whether the
+// message came from an IRC client.
+const echoTimeout = 2 * time.Second
+
+// server accepts IRC clients and relays events from chat to them.
+type server struct {
+	conn     *sechat.Conn
+	listener net.Listener
+	password string
+	log      *logrus.Entry
+	mutex    sync.Mutex
+	clients  map[*client]struct{}
+	names    map[int]string
+	sending  map[int]int
+	sent     map[int]struct{}
+	sentCh   chan bool
+}
+
+// newServer creates a server and begins accepting clients and relaying
+// events.
+func newServer(c *sechat.Conn, l net.Listener, password string) *server {
+	s := &server{
+		conn:     c,
+		listener: l,
+		password: password,
+		log:      logrus.WithField("context", "ircd"),
+		clients:  map[*client]struct{}{},
+		names:    map[int]string{},
+		sending:  map[int]int{},
+		sent:     map[int]struct{}{},
+		sentCh:   make(chan bool),
+	}
+	go s.accept()
+	go s.relay()
+	return s
+}
+
+// accept waits for new clients until the listener is closed.
+func (s *server) accept() {
+	for {
+		nc, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		c := newClient(s, nc)
+		s.mutex.Lock()
+		s.clients[c] = struct{}{}
+		s.mutex.Unlock()
+		go func() {
+			c.run()
+			s.mutex.Lock()
+			delete(s.clients, c)
+			s.mutex.Unlock()
+		}()
+	}
+}
+
+// nick converts a chat display name into a valid IRC nickname.
+func nick(name string) string {
+	n := strings.Map(func(r rune) rune {
+		switch r {
+		case ',', '*', '?', '!', '@', '#', ':', '.':
+			return -1
+		}
+		return r
+	}, format.MentionName(name))
+	if len(n) == 0 {
+		return "unknown"
+	}
+	return n
+}
+
+// hostmask returns the full prefix for a chat user.
+func hostmask(name string, user int) string {
+	return fmt.Sprintf("%s!u%d@chat.stackexchange.com", nick(name), user)
+}
+
+// channel returns the name of the channel for a room.
+func channel(room int) string {
+	return fmt.Sprintf("#%d", room)
+}
+
+// parseChannel returns the room for a channel name or 0 if it is invalid.
+func parseChannel(name string) int {
+	if !strings.HasPrefix(name, "#") {
+		return 0
+	}
+	room, err := strconv.Atoi(name[1:])
+	if err != nil || room <= 0 {
+		return 0
+	}
+	return room
+}
+
+// roomName returns the name of a room if it has been seen in an event.
+func (s *server) roomName(room int) string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.names[room]
+}
+
+// broadcast sends a line to every client in the channel for a room except
+// the one specified (which may be nil).
+func (s *server) broadcast(room int, except *client, lines ...string) {
+	s.mutex.Lock()
+	clients := []*client{}
+	for c := range s.clients {
+		clients = append(clients, c)
+	}
+	s.mutex.Unlock()
+	for _, c := range clients {
+		if c != except && c.inRoom(room) {
+			for _, l := range lines {
+				c.send(l)
+			}
+		}
+	}
+}
+
+// notifySent wakes anything waiting in fromClient. The mutex must be held.
+func (s *server) notifySent() {
+	close(s.sentCh)
+	s.sentCh = make(chan bool)
+}
+
+// send posts a message from an IRC client, recording the IDs of the messages
+// so that they are not relayed back to the clients.
+func (s *server) send(room int, text string) error {
+	s.mutex.Lock()
+	s.sending[room]++
+	s.mutex.Unlock()
+	ids, err := s.conn.SendLong(room, text)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, id := range ids {
+		s.sent[id] = struct{}{}
+	}
+	s.sending[room]--
+	s.notifySent()
+	return err
+}
+
+// fromClient determines if a message was posted by an IRC client. The event
+// may arrive before the send has finished, so if one is in progress for the
+// room, this waits (up to echoTimeout) for it to finish.
+func (s *server) fromClient(e *sechat.Event) bool {
+	timeoutCh := time.After(echoTimeout)
+	for {
+		s.mutex.Lock()
+		_, ok := s.sent[e.MessageID]
+		delete(s.sent, e.MessageID)
+		var (
+			sending = s.sending[e.RoomID]
+			sentCh  = s.sentCh
+		)
+		s.mutex.Unlock()
+		if ok {
+			return true
+		}
+		if sending == 0 {
+			return false
+		}
+		select {
+		case <-sentCh:
+		case <-timeoutCh:
+			return false
+		}
+	}
+}
+
+// privmsgs converts a chat message into one PRIVMSG per line.
+func privmsgs(e *sechat.Event, prefix string) []string {
+	lines := []string{}
+	for _, l := range strings.Split(e.TextContent, "\n") {
+		if len(strings.TrimSpace(l)) == 0 {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf(
+			":%s PRIVMSG %s :%s%s",
+			hostmask(e.UserName, e.UserID),
+			channel(e.RoomID),
+			prefix,
+			l,
+		))
+	}
+	return lines
+}
+
+// relay converts events from chat into IRC messages.
+func (s *server) relay() {
+	for e := range s.conn.Events {
+		if len(e.RoomName) != 0 {
+			s.mutex.Lock()
+			s.names[e.RoomID] = e.RoomName
+			s.mutex.Unlock()
+		}
+		switch e.EventType {
+		case sechat.EventMessagePosted:
+			// Messages sent from IRC were already displayed to the clients;
+			// other messages from the chat account (such as those posted
+			// from a browser) are relayed
+			if e.UserID == s.conn.UserID() && s.fromClient(e) {
+				continue
+			}
+			s.broadcast(e.RoomID, nil, privmsgs(e, "")...)
+		case sechat.EventMessageEdited:
+			s.broadcast(e.RoomID, nil, privmsgs(e, "(edit) ")...)
+		case sechat.EventUserJoined:
+			s.broadcast(e.RoomID, nil, fmt.Sprintf(
+				":%s JOIN %s",
+				hostmask(e.UserName, e.UserID),
+				channel(e.RoomID),
+			))
+		case sechat.EventUserLeft:
+			s.broadcast(e.RoomID, nil, fmt.Sprintf(
+				":%s PART %s",
+				hostmask(e.UserName, e.UserID),
+				channel(e.RoomID),
+			))
+		}
+	}
+}
+
+// inUse determines if any client is in the channel for a room.
+func (s *server) inUse(room int) bool {
+	s.mutex.Lock()
+	clients := []*client{}
+	for c := range s.clients {
+		clients = append(clients, c)
+	}
+	s.mutex.Unlock()
+	for _, c := range clients {
+		if c.inRoom(room) {
+			return true
+		}
+	}
+	return false
+}
+
+// close stops accepting clients and disconnects those that are connected.
+func (s *server) close() {
+	s.listener.Close()
+	s.mutex.Lock()
+	for c := range s.clients {
+		c.close()
+	}
+	s.mutex.Unlock()
+}
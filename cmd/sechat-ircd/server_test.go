@@ -0,0 +1,247 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nathan-osman/go-sechat"
+)
+
+func TestNick(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		output string
+	}{
+		{"alice", "alice"},
+		{"John Doe", "JohnDoe"},
+		{"a.b@c!d", "abcd"},
+		{"#:,*?", "unknown"},
+		{"", "unknown"},
+	} {
+		if v := nick(test.name); v != test.output {
+			t.Fatalf("%q: %q != %q", test.name, v, test.output)
+		}
+	}
+}
+
+func TestParseChannel(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		output int
+	}{
+		{"#201", 201},
+		{channel(42), 42},
+		{"201", 0},
+		{"#abc", 0},
+		{"#0", 0},
+		{"#-1", 0},
+		{"#", 0},
+	} {
+		if v := parseChannel(test.name); v != test.output {
+			t.Fatalf("%q: %d != %d", test.name, v, test.output)
+		}
+	}
+}
+
+// newTestServer creates a server that is not connected to chat.
+func newTestServer() *server {
+	return &server{
+		clients: map[*client]struct{}{},
+		names:   map[int]string{},
+		sending: map[int]int{},
+		sent:    map[int]struct{}{},
+		sentCh:  make(chan bool),
+	}
+}
+
+func TestBroadcastExcept(t *testing.T) {
+	var (
+		s      = newTestServer()
+		a1, a2 = net.Pipe()
+		b1, b2 = net.Pipe()
+		a      = newClient(s, a1)
+		b      = newClient(s, b1)
+	)
+	defer a2.Close()
+	defer b2.Close()
+	for _, c := range []*client{a, b} {
+		c.rooms[201] = struct{}{}
+		s.clients[c] = struct{}{}
+	}
+	go s.broadcast(201, a, "line")
+	b2.SetReadDeadline(time.Now().Add(time.Second))
+	if l, err := bufio.NewReader(b2).ReadString('\n'); err != nil || l != "line\r\n" {
+		t.Fatalf("%q, %v", l, err)
+	}
+	a2.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	if _, err := a2.Read(make([]byte, 1)); err == nil {
+		t.Fatal("message sent to excluded client")
+	}
+}
+
+func TestFromClient(t *testing.T) {
+	s := newTestServer()
+	e := &sechat.Event{MessageID: 1, RoomID: 201}
+	if s.fromClient(e) {
+		t.Fatal("unknown message reported as sent")
+	}
+	// The event arrives while the send is still in progress
+	s.sending[201] = 1
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		s.mutex.Lock()
+		s.sent[1] = struct{}{}
+		s.sending[201]--
+		s.notifySent()
+		s.mutex.Unlock()
+	}()
+	if !s.fromClient(e) {
+		t.Fatal("sent message not detected")
+	}
+	if s.fromClient(e) {
+		t.Fatal("sent message detected twice")
+	}
+}
+
+func TestSlowClient(t *testing.T) {
+	defer func(d time.Duration) { writeTimeout = d }(writeTimeout)
+	writeTimeout = 50 * time.Millisecond
+	var (
+		s      = newTestServer()
+		c1, c2 = net.Pipe()
+		c      = newClient(s, c1)
+	)
+	defer c2.Close()
+	// Nothing reads from the other end of the pipe
+	c.send("line")
+	c2.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := c2.Read(make([]byte, 1)); err != io.EOF {
+		t.Fatalf("%v != %v", err, io.EOF)
+	}
+}
+
+// testClient is an IRC client connected to a server under test.
+type testClient struct {
+	conn  net.Conn
+	lines chan string
+}
+
+// dial connects to the server and registers with the provided nickname.
+func dial(t *testing.T, addr, nick string) *testClient {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &testClient{
+		conn:  conn,
+		lines: make(chan string, 100),
+	}
+	go func() {
+		defer close(c.lines)
+		scanner := bufio.NewScanner(conn)
+		for scanner.Scan() {
+			c.lines <- scanner.Text()
+		}
+	}()
+	c.write(t, "NICK "+nick, "USER "+nick+" 0 * :"+nick)
+	c.expect(t, " 422 ")
+	return c
+}
+
+// write sends lines to the server.
+func (c *testClient) write(t *testing.T, lines ...string) {
+	for _, l := range lines {
+		if _, err := fmt.Fprintf(c.conn, "%s\r\n", l); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// next returns the next line received from the server.
+func (c *testClient) next(t *testing.T) string {
+	select {
+	case l, ok := <-c.lines:
+		if !ok {
+			t.Fatal("connection closed")
+		}
+		return l
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for line")
+	}
+	return ""
+}
+
+// expect skips lines until one containing the text is received.
+func (c *testClient) expect(t *testing.T, text string) {
+	for !strings.Contains(c.next(t), text) {
+	}
+}
+
+func TestServer(t *testing.T) {
+	var (
+		mutex  sync.Mutex
+		nextID = 100
+		posted = make(chan string, 10)
+		events = make(chan *sechat.Event)
+	)
+	conn := sechat.NewTestConn(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/chats/201/messages/new" {
+			mutex.Lock()
+			nextID++
+			fmt.Fprintf(w, `{"id":%d}`, nextID)
+			mutex.Unlock()
+			posted <- r.FormValue("text")
+			return
+		}
+		fmt.Fprint(w, "{}")
+	}), 1, "bot")
+	conn.Events = events
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newServer(conn, l, "")
+	defer s.close()
+	var (
+		a = dial(t, l.Addr().String(), "alice")
+		b = dial(t, l.Addr().String(), "bob")
+	)
+	// Messages may only be sent to channels that have been joined
+	a.write(t, "PRIVMSG #201 :hi")
+	a.expect(t, " 404 alice #201 ")
+	for _, c := range []*testClient{a, b} {
+		c.write(t, "JOIN #201")
+		c.expect(t, " 366 ")
+	}
+	a.write(t, "PRIVMSG #201 :bob: hi")
+	if v := b.next(t); v != ":alice!alice@localhost PRIVMSG #201 :bob: hi" {
+		t.Fatalf("%q", v)
+	}
+	select {
+	case v := <-posted:
+		if v != "@bob hi" {
+			t.Fatalf("%q != @bob hi", v)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("message not posted")
+	}
+	// The echo of the message must not be relayed but others must be
+	for _, e := range []*sechat.Event{
+		{EventType: sechat.EventMessagePosted, MessageID: 101, RoomID: 201, UserID: 1, UserName: "bot", TextContent: "@bob hi"},
+		{EventType: sechat.EventMessagePosted, MessageID: 102, RoomID: 201, UserID: 2, UserName: "Carol", TextContent: "hello"},
+	} {
+		events <- e
+	}
+	for _, c := range []*testClient{a, b} {
+		if v := c.next(t); v != ":Carol!u2@chat.stackexchange.com PRIVMSG #201 :hello" {
+			t.Fatalf("%q", v)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+// Command sechat-tui is an interactive terminal client for the Stack Exchange
+// chat network.
+//
+// Each room specified on the command line is opened in its own tab. Type a
+// message and press Enter to send it. The following keys are available:
+//
+//	Ctrl-N, Ctrl-P   switch to the next or previous room
+//	Tab              move between the input and the message pane
+//	Up, Down         select a message (in the message pane)
+//	r                reply to the selected message
+//	s                star the selected message
+//	Esc              cancel the reply or selection
+//
+// The input also accepts the commands /join <room>, /leave, and /quit.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/nathan-osman/go-sechat"
+	"github.com/nathan-osman/go-sechat/internal/config"
+	"github.com/sirupsen/logrus"
+)
+
+var errConnect = errors.New("unable to connect to chat")
+
+func main() {
+	configPath := flag.String("config", config.DefaultPath(), "path to config file")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [options] <room>...\n\nOptions:\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	rooms := []int{}
+	for _, a := range flag.Args() {
+		r, err := strconv.Atoi(a)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid room %q\n", a)
+			os.Exit(2)
+		}
+		rooms = append(rooms, r)
+	}
+	if err := run(*configPath, rooms); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+}
+
+// run connects to chat, joins the rooms, and runs the interface until the
+// user quits.
+func run(configPath string, rooms []int) error {
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		return err
+	}
+	// Log messages would corrupt the display
+	logrus.SetLevel(logrus.PanicLevel)
+	fmt.Println("Connecting...")
+	c, err := sechat.New(cfg.Email, cfg.Password, rooms[0])
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if !c.WaitForConnected() {
+		return errConnect
+	}
+	for _, r := range rooms[1:] {
+		if err := c.Join(r); err != nil {
+			return err
+		}
+	}
+	return newUI(c, rooms).run()
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/nathan-osman/go-sechat"
+)
+
+// maxMessages is the number of messages kept for each room.
+const maxMessages = 500
+
+// room stores the messages received for a single room.
+type room struct {
+	id        int
+	name      string
+	messages  []*sechat.Event
+	mentions  map[int]bool
+	unread    int
+	mentioned bool
+}
+
+// newRoom creates an empty room.
+func newRoom(id int) *room {
+	return &room{
+		id:       id,
+		name:     fmt.Sprintf("#%d", id),
+		messages: []*sechat.Event{},
+		mentions: map[int]bool{},
+	}
+}
+
+// find returns the index of the specified message or -1 if it isn't present.
+func (r *room) find(message int) int {
+	for i, m := range r.messages {
+		if m.MessageID == message {
+			return i
+		}
+	}
+	return -1
+}
+
+// handle updates the room using an event. True is returned if the event
+// changed the messages in the room.
+func (r *room) handle(c *sechat.Conn, e *sechat.Event) bool {
+	if len(e.RoomName) != 0 {
+		r.name = e.RoomName
+	}
+	switch e.EventType {
+	case sechat.EventMessagePosted:
+		if r.find(e.MessageID) != -1 {
+			return false
+		}
+		r.messages = append(r.messages, e)
+		if len(r.messages) > maxMessages {
+			delete(r.mentions, r.messages[0].MessageID)
+			r.messages = r.messages[1:]
+		}
+		if e.UserID != c.UserID() {
+			r.unread++
+			if e.MentionsMe(c) {
+				r.mentions[e.MessageID] = true
+				r.mentioned = true
+			}
+		}
+		return true
+	case sechat.EventUserMentioned, sechat.EventMessageReply:
+		r.mentions[e.MessageID] = true
+		r.mentioned = true
+		return true
+	case sechat.EventMessageEdited, sechat.EventMessageStarred:
+		i := r.find(e.MessageID)
+		if i == -1 {
+			return false
+		}
+		m := *r.messages[i]
+		if e.EventType == sechat.EventMessageEdited {
+			m.Content, m.TextContent, m.MessageEdits = e.Content, e.TextContent, e.MessageEdits
+		} else {
+			m.MessageStars = e.MessageStars
+		}
+		r.messages[i] = &m
+		return true
+	case sechat.EventMessageDeleted:
+		i := r.find(e.MessageID)
+		if i == -1 {
+			return false
+		}
+		m := *r.messages[i]
+		m.TextContent = "(removed)"
+		r.messages[i] = &m
+		return true
+	}
+	return false
+}
+
+// markRead clears the unread count and mention flag.
+func (r *room) markRead() {
+	r.unread = 0
+	r.mentioned = false
+}
@@ -0,0 +1,384 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/nathan-osman/go-sechat"
+	"github.com/nathan-osman/go-sechat/format"
+	"github.com/rivo/tview"
+)
+
+// ui manages the terminal interface. All fields other than conn and app must
+// only be accessed from the application's goroutine.
+type ui struct {
+	conn     *sechat.Conn
+	app      *tview.Application
+	tabs     *tview.TextView
+	messages *tview.TextView
+	users    *tview.TextView
+	status   *tview.TextView
+	input    *tview.InputField
+	rooms    []*room
+	current  int
+	selected int
+	replyTo  *sechat.Event
+}
+
+// newUI creates the interface with a tab for each of the rooms.
+func newUI(c *sechat.Conn, rooms []int) *ui {
+	u := &ui{
+		conn:     c,
+		app:      tview.NewApplication(),
+		tabs:     tview.NewTextView().SetDynamicColors(true).SetRegions(true),
+		messages: tview.NewTextView().SetDynamicColors(true).SetRegions(true).SetWordWrap(true),
+		users:    tview.NewTextView().SetDynamicColors(true),
+		status:   tview.NewTextView().SetDynamicColors(true),
+		input:    tview.NewInputField().SetLabel("> "),
+		selected: -1,
+	}
+	for _, r := range rooms {
+		u.rooms = append(u.rooms, newRoom(r))
+	}
+	u.messages.SetBorder(true)
+	u.users.SetBorder(true).SetTitle("Users")
+	u.messages.SetInputCapture(u.messagesKey)
+	u.input.SetDoneFunc(u.inputDone)
+	u.app.SetInputCapture(u.globalKey)
+	var (
+		body = tview.NewFlex().
+			AddItem(u.messages, 0, 1, false).
+			AddItem(u.users, 24, 0, false)
+		layout = tview.NewFlex().SetDirection(tview.FlexRow).
+			AddItem(u.tabs, 1, 0, false).
+			AddItem(body, 0, 1, false).
+			AddItem(u.status, 1, 0, false).
+			AddItem(u.input, 1, 0, true)
+	)
+	u.app.SetRoot(layout, true)
+	return u
+}
+
+// run processes events and runs the interface until the user quits.
+func (u *ui) run() error {
+	go u.receive()
+	for _, r := range u.rooms {
+		go u.seed(r.id)
+	}
+	u.render()
+	return u.app.Run()
+}
+
+// receive passes events from the connection to the interface.
+func (u *ui) receive() {
+	for e := range u.conn.Events {
+		e := e
+		u.app.QueueUpdateDraw(func() {
+			u.handle(e)
+		})
+	}
+	u.app.QueueUpdateDraw(func() {
+		u.setStatus("[red]disconnected")
+	})
+}
+
+// seed loads the users in a room in the background.
+func (u *ui) seed(room int) {
+	if err := u.conn.Directory.Seed(room); err != nil {
+		u.app.QueueUpdateDraw(func() {
+			u.setStatus(fmt.Sprintf("[red]%s", tview.Escape(err.Error())))
+		})
+		return
+	}
+	u.app.QueueUpdateDraw(u.renderUsers)
+}
+
+// room returns the room with the specified ID or nil.
+func (u *ui) room(id int) *room {
+	for _, r := range u.rooms {
+		if r.id == id {
+			return r
+		}
+	}
+	return nil
+}
+
+// handle updates the interface with an event.
+func (u *ui) handle(e *sechat.Event) {
+	r := u.room(e.RoomID)
+	if r == nil {
+		return
+	}
+	if r.handle(u.conn, e) {
+		if r == u.rooms[u.current] {
+			r.markRead()
+			u.renderMessages()
+		}
+		u.renderTabs()
+	}
+	switch e.EventType {
+	case sechat.EventUserJoined, sechat.EventUserLeft:
+		if r == u.rooms[u.current] {
+			u.renderUsers()
+		}
+	}
+}
+
+// render redraws every part of the interface.
+func (u *ui) render() {
+	u.rooms[u.current].markRead()
+	u.renderTabs()
+	u.renderMessages()
+	u.renderUsers()
+	u.renderStatus()
+}
+
+// renderTabs draws the list of rooms, including unread counts.
+func (u *ui) renderTabs() {
+	b := &bytes.Buffer{}
+	for i, r := range u.rooms {
+		color := "white"
+		switch {
+		case i == u.current:
+			color = "black:white"
+		case r.mentioned:
+			color = "yellow"
+		case r.unread != 0:
+			color = "green"
+		}
+		fmt.Fprintf(b, "[%s] %s ", color, tview.Escape(r.name))
+		if r.unread != 0 && i != u.current {
+			fmt.Fprintf(b, "(%d) ", r.unread)
+		}
+		b.WriteString("[-:-] ")
+	}
+	u.tabs.SetText(b.String())
+}
+
+// renderMessages draws the messages in the current room. Each message is a
+// separate region so that it can be selected.
+func (u *ui) renderMessages() {
+	var (
+		r = u.rooms[u.current]
+		b = &bytes.Buffer{}
+	)
+	u.messages.SetTitle(fmt.Sprintf(" %s ", tview.Escape(r.name)))
+	for _, m := range r.messages {
+		color := "white"
+		if r.mentions[m.MessageID] {
+			color = "yellow"
+		}
+		fmt.Fprintf(
+			b,
+			"[\"%d\"][gray]%s[-] [::b]%s[::-]: [%s]%s[-]",
+			m.MessageID,
+			time.Unix(int64(m.TimeStamp), 0).Format("15:04"),
+			tview.Escape(m.UserName),
+			color,
+			tview.Escape(m.TextContent),
+		)
+		if m.MessageStars != 0 {
+			fmt.Fprintf(b, " [yellow]★%d[-]", m.MessageStars)
+		}
+		b.WriteString("[\"\"]\n")
+	}
+	u.messages.SetText(b.String())
+	if u.selected >= 0 && u.selected < len(r.messages) {
+		u.messages.Highlight(strconv.Itoa(r.messages[u.selected].MessageID))
+		u.messages.ScrollToHighlight()
+	} else {
+		u.messages.Highlight()
+		u.messages.ScrollToEnd()
+	}
+}
+
+// renderUsers draws the users present in the current room.
+func (u *ui) renderUsers() {
+	b := &bytes.Buffer{}
+	for _, p := range u.conn.Directory.Present(u.rooms[u.current].id) {
+		fmt.Fprintf(b, "%s\n", tview.Escape(p.Name))
+	}
+	u.users.SetText(b.String())
+}
+
+// renderStatus shows the message being replied to, if any.
+func (u *ui) renderStatus() {
+	if u.replyTo != nil {
+		u.setStatus(fmt.Sprintf(
+			"[gray]replying to %s: %s",
+			tview.Escape(u.replyTo.UserName),
+			tview.Escape(u.replyTo.TextContent),
+		))
+	} else {
+		u.setStatus("")
+	}
+}
+
+// setStatus displays text in the status line.
+func (u *ui) setStatus(text string) {
+	u.status.SetText(text)
+}
+
+// switchRoom makes the room at the specified index current.
+func (u *ui) switchRoom(i int) {
+	u.current = (i + len(u.rooms)) % len(u.rooms)
+	u.selected = -1
+	u.replyTo = nil
+	u.render()
+}
+
+// globalKey handles keys that work regardless of focus.
+func (u *ui) globalKey(ev *tcell.EventKey) *tcell.EventKey {
+	switch ev.Key() {
+	case tcell.KeyCtrlN:
+		u.switchRoom(u.current + 1)
+		return nil
+	case tcell.KeyCtrlP:
+		u.switchRoom(u.current - 1)
+		return nil
+	case tcell.KeyTab:
+		if u.input.HasFocus() {
+			u.app.SetFocus(u.messages)
+			if n := len(u.rooms[u.current].messages); u.selected < 0 && n != 0 {
+				u.selected = n - 1
+				u.renderMessages()
+			}
+		} else {
+			u.app.SetFocus(u.input)
+		}
+		return nil
+	}
+	return ev
+}
+
+// messagesKey handles keys for selecting messages in the message pane.
+func (u *ui) messagesKey(ev *tcell.EventKey) *tcell.EventKey {
+	r := u.rooms[u.current]
+	switch ev.Key() {
+	case tcell.KeyUp:
+		if u.selected > 0 {
+			u.selected--
+			u.renderMessages()
+		}
+		return nil
+	case tcell.KeyDown:
+		if u.selected < len(r.messages)-1 {
+			u.selected++
+			u.renderMessages()
+		}
+		return nil
+	case tcell.KeyEscape:
+		u.selected = -1
+		u.renderMessages()
+		u.app.SetFocus(u.input)
+		return nil
+	}
+	if u.selected < 0 || u.selected >= len(r.messages) {
+		return ev
+	}
+	m := r.messages[u.selected]
+	switch ev.Rune() {
+	case 'r':
+		u.replyTo = m
+		u.renderStatus()
+		u.app.SetFocus(u.input)
+		return nil
+	case 's':
+		go u.do(func() error {
+			return u.conn.Star(m.MessageID)
+		})
+		return nil
+	}
+	return ev
+}
+
+// do runs an action in the background (since requests may block while
+// throttled) and displays any error in the status line.
+func (u *ui) do(fn func() error) {
+	if err := fn(); err != nil {
+		u.app.QueueUpdateDraw(func() {
+			u.setStatus(fmt.Sprintf("[red]%s", tview.Escape(err.Error())))
+		})
+	}
+}
+
+// inputDone sends the text in the input or runs the command it contains.
+func (u *ui) inputDone(key tcell.Key) {
+	if key == tcell.KeyEscape {
+		u.replyTo = nil
+		u.renderStatus()
+		return
+	}
+	if key != tcell.KeyEnter {
+		return
+	}
+	text := strings.TrimSpace(u.input.GetText())
+	u.input.SetText("")
+	if len(text) == 0 {
+		return
+	}
+	if strings.HasPrefix(text, "/") {
+		u.command(strings.Fields(text))
+		return
+	}
+	room := u.rooms[u.current].id
+	if u.replyTo != nil {
+		text = format.Reply(u.replyTo.MessageID, text)
+		u.replyTo = nil
+		u.renderStatus()
+	}
+	go u.do(func() error {
+		_, err := u.conn.SendLong(room, text)
+		return err
+	})
+}
+
+// command runs one of the commands entered in the input.
+func (u *ui) command(args []string) {
+	switch args[0] {
+	case "/quit":
+		u.app.Stop()
+	case "/join":
+		if len(args) != 2 {
+			u.setStatus("[red]usage: /join <room>")
+			return
+		}
+		id, err := strconv.Atoi(args[1])
+		if err != nil {
+			u.setStatus("[red]invalid room")
+			return
+		}
+		if u.room(id) == nil {
+			u.rooms = append(u.rooms, newRoom(id))
+		}
+		for i, r := range u.rooms {
+			if r.id == id {
+				u.switchRoom(i)
+			}
+		}
+		go u.do(func() error {
+			if err := u.conn.Join(id); err != nil {
+				return err
+			}
+			u.seed(id)
+			return nil
+		})
+	case "/leave":
+		if len(u.rooms) == 1 {
+			u.setStatus("[red]cannot leave the last room")
+			return
+		}
+		id := u.rooms[u.current].id
+		u.rooms = append(u.rooms[:u.current:u.current], u.rooms[u.current+1:]...)
+		u.switchRoom(u.current)
+		go u.do(func() error {
+			return u.conn.Leave(id)
+		})
+	default:
+		u.setStatus(fmt.Sprintf("[red]unknown command %s", tview.Escape(args[0])))
+	}
+}
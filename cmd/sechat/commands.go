@@ -0,0 +1,270 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nathan-osman/go-sechat"
+	"github.com/nathan-osman/go-sechat/format"
+)
+
+// parseIDs converts each of the arguments to an integer ID.
+func parseIDs(args []string) ([]int, error) {
+	ids := make([]int, len(args))
+	for i, a := range args {
+		v, err := strconv.Atoi(a)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ID %q", a)
+		}
+		ids[i] = v
+	}
+	return ids, nil
+}
+
+func cmdSend(c *sechat.Conn, args []string) error {
+	if len(args) < 2 {
+		return errUsage
+	}
+	ids, err := parseIDs(args[:1])
+	if err != nil {
+		return err
+	}
+	messages, err := c.SendLong(ids[0], strings.Join(args[1:], " "))
+	if err != nil {
+		return err
+	}
+	for _, m := range messages {
+		fmt.Println(m)
+	}
+	return nil
+}
+
+func cmdReply(c *sechat.Conn, args []string) error {
+	if len(args) < 3 {
+		return errUsage
+	}
+	ids, err := parseIDs(args[:2])
+	if err != nil {
+		return err
+	}
+	m, err := c.SendMessage(
+		ids[0],
+		format.Reply(ids[1], strings.Join(args[2:], " ")),
+	)
+	if err != nil {
+		return err
+	}
+	fmt.Println(m)
+	return nil
+}
+
+func cmdStar(c *sechat.Conn, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	ids, err := parseIDs(args)
+	if err != nil {
+		return err
+	}
+	return c.Star(ids[0])
+}
+
+func cmdJoin(c *sechat.Conn, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	ids, err := parseIDs(args)
+	if err != nil {
+		return err
+	}
+	return c.Join(ids[0])
+}
+
+func cmdLeave(c *sechat.Conn, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	ids, err := parseIDs(args)
+	if err != nil {
+		return err
+	}
+	return c.Leave(ids[0])
+}
+
+func cmdUsers(c *sechat.Conn, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	ids, err := parseIDs(args)
+	if err != nil {
+		return err
+	}
+	users, err := c.UsersInRoom(ids[0])
+	if err != nil {
+		return err
+	}
+	for _, u := range users {
+		fmt.Printf("%d\t%s\n", u.ID, u.Name)
+	}
+	return nil
+}
+
+func cmdWhois(c *sechat.Conn, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	ids, err := parseIDs(args)
+	if err != nil {
+		return err
+	}
+	u, err := c.User(ids[0])
+	if err != nil {
+		return err
+	}
+	fmt.Printf("ID:         %d\n", u.ID)
+	fmt.Printf("Name:       %s\n", u.Name)
+	fmt.Printf("Reputation: %d\n", u.Reputation)
+	fmt.Printf("Moderator:  %t\n", u.IsModerator)
+	if len(u.UserMessage) != 0 {
+		fmt.Printf("Message:    %s\n", u.UserMessage)
+	}
+	if len(u.Host) != 0 {
+		fmt.Printf("Site:       %s\n", u.Host)
+	}
+	if len(u.ProfileURL) != 0 {
+		fmt.Printf("Profile:    %s\n", u.ProfileURL)
+	}
+	for _, r := range u.Rooms {
+		fmt.Printf("Room:       %d\t%s\n", r.ID, r.Name)
+	}
+	return nil
+}
+
+func cmdUpload(c *sechat.Conn, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	f, err := os.Open(args[0])
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	opts := &sechat.UploadOptions{Filename: filepath.Base(args[0])}
+	if fi, err := f.Stat(); err == nil {
+		opts.Size = fi.Size()
+	}
+	u, err := c.UploadImage(f, opts)
+	if err != nil {
+		return err
+	}
+	fmt.Println(u)
+	return nil
+}
+
+func cmdNewRoom(c *sechat.Conn, args []string) error {
+	var (
+		flags  = flag.NewFlagSet("new-room", flag.ContinueOnError)
+		access = flags.String("access", sechat.AccessReadWrite, "default access level")
+	)
+	if err := flags.Parse(args); err != nil {
+		return errUsage
+	}
+	if flags.NArg() != 3 {
+		return errUsage
+	}
+	r, err := c.NewRoom(flags.Arg(0), flags.Arg(1), flags.Arg(2), *access)
+	if err != nil {
+		return err
+	}
+	fmt.Println(r)
+	return nil
+}
+
+func cmdInvite(c *sechat.Conn, args []string) error {
+	if len(args) != 2 {
+		return errUsage
+	}
+	ids, err := parseIDs(args)
+	if err != nil {
+		return err
+	}
+	return c.Invite(ids[0], ids[1])
+}
+
+// formatEvent converts an event to a single line of text.
+func formatEvent(e *sechat.Event) string {
+	t := time.Unix(int64(e.TimeStamp), 0).Format("15:04:05")
+	switch e.EventType {
+	case sechat.EventMessagePosted:
+		return fmt.Sprintf("%s [%d] <%s> %s", t, e.RoomID, e.UserName, e.TextContent)
+	case sechat.EventMessageEdited:
+		return fmt.Sprintf("%s [%d] <%s> (edited) %s", t, e.RoomID, e.UserName, e.TextContent)
+	case sechat.EventMessageDeleted:
+		return fmt.Sprintf("%s [%d] * message %d deleted", t, e.RoomID, e.MessageID)
+	case sechat.EventUserJoined:
+		return fmt.Sprintf("%s [%d] * %s joined", t, e.RoomID, e.UserName)
+	case sechat.EventUserLeft:
+		return fmt.Sprintf("%s [%d] * %s left", t, e.RoomID, e.UserName)
+	case sechat.EventMessageStarred:
+		return fmt.Sprintf("%s [%d] * message %d starred (%d)", t, e.RoomID, e.MessageID, e.MessageStars)
+	default:
+		return ""
+	}
+}
+
+func cmdTail(c *sechat.Conn, args []string) error {
+	var (
+		flags   = flag.NewFlagSet("tail", flag.ContinueOnError)
+		useJSON = flags.Bool("json", false, "write events as JSON lines")
+	)
+	if err := flags.Parse(args); err != nil {
+		return errUsage
+	}
+	if flags.NArg() == 0 {
+		return errUsage
+	}
+	rooms, err := parseIDs(flags.Args())
+	if err != nil {
+		return err
+	}
+	want := map[int]struct{}{}
+	for _, r := range rooms {
+		if err := c.Join(r); err != nil {
+			return err
+		}
+		want[r] = struct{}{}
+	}
+	var (
+		enc   = json.NewEncoder(os.Stdout)
+		sigCh = make(chan os.Signal, 1)
+	)
+	signal.Notify(sigCh, os.Interrupt)
+	for {
+		select {
+		case e, ok := <-c.Events:
+			if !ok {
+				return errors.New("connection closed")
+			}
+			if _, ok := want[e.RoomID]; !ok {
+				continue
+			}
+			if *useJSON {
+				if err := enc.Encode(e); err != nil {
+					return err
+				}
+			} else if s := formatEvent(e); len(s) != 0 {
+				fmt.Println(s)
+			}
+		case <-sigCh:
+			return nil
+		}
+	}
+}
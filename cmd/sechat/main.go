@@ -0,0 +1,106 @@
+// Command sechat provides access to the Stack Exchange chat network from the
+// command line.
+//
+// Credentials are read from the SECHAT_EMAIL and SECHAT_PASSWORD environment
+// variables or from a JSON config file containing "email" and "password".
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/nathan-osman/go-sechat"
+	"github.com/nathan-osman/go-sechat/internal/config"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	errConnect = errors.New("unable to connect to chat")
+	errUsage   = errors.New("invalid arguments")
+)
+
+// command describes a subcommand and the function that runs it.
+type command struct {
+	usage string
+	run   func(c *sechat.Conn, args []string) error
+}
+
+// commands maps subcommand names to their implementation.
+var commands = map[string]*command{
+	"send":     {"send <room> <text>", cmdSend},
+	"reply":    {"reply <room> <message> <text>", cmdReply},
+	"star":     {"star <message>", cmdStar},
+	"join":     {"join <room>", cmdJoin},
+	"leave":    {"leave <room>", cmdLeave},
+	"users":    {"users <room>", cmdUsers},
+	"whois":    {"whois <user>", cmdWhois},
+	"upload":   {"upload <file>", cmdUpload},
+	"new-room": {"new-room [-access level] <name> <description> <host>", cmdNewRoom},
+	"invite":   {"invite <user> <room>", cmdInvite},
+	"tail":     {"tail [-json] <room>...", cmdTail},
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s [options] <command> [args]\n\nCommands:\n", os.Args[0])
+	names := []string{}
+	for n := range commands {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	for _, n := range names {
+		fmt.Fprintf(os.Stderr, "  %s\n", commands[n].usage)
+	}
+	fmt.Fprintf(os.Stderr, "\nOptions:\n")
+	flag.PrintDefaults()
+}
+
+func main() {
+	var (
+		configPath = flag.String("config", config.DefaultPath(), "path to config file")
+		room       = flag.Int("room", 1, "room used for the initial connection")
+		verbose    = flag.Bool("verbose", false, "show log messages")
+	)
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+	cmd, ok := commands[flag.Arg(0)]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", flag.Arg(0))
+		usage()
+		os.Exit(2)
+	}
+	if !*verbose {
+		logrus.SetLevel(logrus.WarnLevel)
+	}
+	if err := run(*configPath, *room, cmd, flag.Args()[1:]); err != nil {
+		if err == errUsage {
+			fmt.Fprintf(os.Stderr, "usage: %s %s\n", os.Args[0], cmd.usage)
+			os.Exit(2)
+		}
+		fmt.Fprintf(os.Stderr, "%s: %s\n", flag.Arg(0), err)
+		os.Exit(1)
+	}
+}
+
+// run connects to chat and runs the command.
+func run(configPath string, room int, cmd *command, args []string) error {
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		return err
+	}
+	c, err := sechat.New(cfg.Email, cfg.Password, room)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if !c.WaitForConnected() {
+		return errConnect
+	}
+	return cmd.run(c, args)
+}
@@ -1,6 +1,7 @@
 package sechat
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
@@ -18,6 +19,8 @@ const (
 	AccessReadWrite = "read-write"
 	AccessReadOnly  = "read-only"
 	AccessRequest   = "request"
+	AccessOwner     = "owner"
+	AccessRemove    = "remove"
 )
 
 var ErrRoomID = errors.New("unable to find room ID")
@@ -33,19 +36,26 @@ var roomRegexp = regexp.MustCompile(`^(?:https?://chat.stackexchange.com)?/rooms
 // requests are used to trigger actions and websockets are used for event
 // notifications.
 type Conn struct {
-	Events      <-chan *Event
-	connectedCh chan bool
-	closeCh     chan bool
-	closedCh    chan bool
-	client      *http.Client
-	conn        *websocket.Conn
-	log         *logrus.Entry
-	mutex       sync.Mutex
-	email       string
-	password    string
-	fkey        string
-	room        int
-	user        int
+	Events       <-chan *Event
+	Directory    *UserDirectory
+	connectedCh  chan bool
+	closeCh      chan bool
+	closedCh     chan bool
+	client       *http.Client
+	conn         *websocket.Conn
+	log          *logrus.Entry
+	mutex        sync.Mutex
+	filterMutex  sync.Mutex
+	filters      []*eventFilter
+	nextFilterID int
+	storeMutex   sync.RWMutex
+	stores       []EventStore
+	email        string
+	password     string
+	fkey         string
+	room         int
+	user         int
+	userName     string
 }
 
 // atoi removes the error handling from Atoi() and ensures a value is always
@@ -88,15 +98,25 @@ func New(email, password string, room int) (*Conn, error) {
 			room:     room,
 		}
 	)
+	c.Directory = newUserDirectory(c)
 	go c.run(ch)
 	return c, nil
 }
 
 // UserID returns the chat ID of the current user.
 func (c *Conn) UserID() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
 	return c.user
 }
 
+// UserName returns the display name of the current user.
+func (c *Conn) UserName() string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.userName
+}
+
 // WaitForConnected waits until authentication is complete and the websocket is
 // connected.
 func (c *Conn) WaitForConnected() bool {
@@ -183,6 +203,26 @@ func (c *Conn) Send(room int, text string) error {
 	return err
 }
 
+// SendMessage posts the specified message to the specified room and returns
+// the ID of the new message.
+func (c *Conn) SendMessage(room int, text string) (int, error) {
+	res, err := c.postForm(
+		fmt.Sprintf("/chats/%d/messages/new", room),
+		&url.Values{"text": {text}},
+	)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	var v struct {
+		ID int `json:"id"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&v); err != nil {
+		return 0, err
+	}
+	return v.ID, nil
+}
+
 // Reply sends a reply for the specified event.
 func (c *Conn) Reply(e *Event, text string) error {
 	return c.Send(
@@ -200,6 +240,25 @@ func (c *Conn) Star(message int) error {
 	return err
 }
 
+// Edit replaces the text of the specified message. Chat only permits messages
+// to be edited for a short time after they are posted.
+func (c *Conn) Edit(message int, text string) error {
+	_, err := c.postForm(
+		fmt.Sprintf("/messages/%d", message),
+		&url.Values{"text": {text}},
+	)
+	return err
+}
+
+// Delete removes the specified message.
+func (c *Conn) Delete(message int) error {
+	_, err := c.postForm(
+		fmt.Sprintf("/messages/%d/delete", message),
+		&url.Values{},
+	)
+	return err
+}
+
 // Close disconnects the websocket and shuts down the connection.
 func (c *Conn) Close() {
 	// Indicate that the connection is closing
@@ -0,0 +1,35 @@
+package sechat
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestSend(t *testing.T) {
+	var body string
+	c := newTestConn(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/chats/201/messages/new" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(body))
+	})
+	body = `{"id":123,"time":1}`
+	id, err := c.SendMessage(201, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != 123 {
+		t.Fatalf("%d != 123", id)
+	}
+	body = "unexpected"
+	if err := c.Send(201, "test"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.SendMessage(201, "test"); err == nil {
+		t.Fatal("error expected")
+	}
+	if err := c.Send(202, "test"); err == nil {
+		t.Fatal("error expected")
+	}
+}
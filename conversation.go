@@ -0,0 +1,112 @@
+package sechat
+
+import (
+	"errors"
+	"time"
+
+	"github.com/nathan-osman/go-sechat/format"
+)
+
+// DefaultConversationTimeout is the length of time that a conversation waits
+// for an answer unless a different timeout is set.
+const DefaultConversationTimeout = 5 * time.Minute
+
+var (
+	ErrTimeout    = errors.New("timed out waiting for a response")
+	ErrConnClosed = errors.New("connection closed")
+)
+
+// Conversation simplifies multi-step dialogs with a single user in a room.
+// Messages that are received as answers are consumed and do not appear on the
+// Events channel. Only one question should be pending at a time.
+type Conversation struct {
+	Timeout time.Duration
+	conn    *Conn
+	room    int
+	user    int
+}
+
+// NewConversation creates a conversation with the specified user in the
+// specified room.
+func (c *Conn) NewConversation(room, user int) *Conversation {
+	return &Conversation{
+		Timeout: DefaultConversationTimeout,
+		conn:    c,
+		room:    room,
+		user:    user,
+	}
+}
+
+// wait registers a filter for the answer and then invokes send. The answer is
+// the first message posted by the user in the room or, once the prompt's ID is
+// known, a reply notification for the user's reply to the prompt. Messages
+// from anyone else are never treated as an answer.
+func (cv *Conversation) wait(send func() (int, error)) (*Event, error) {
+	var (
+		answerCh = make(chan *Event, 1)
+		prompt   = make(chan int, 1)
+		promptID int
+	)
+	id := cv.conn.addFilter(func(e *Event) bool {
+		if e.UserID != cv.user || e.RoomID != cv.room {
+			return false
+		}
+		// The prompt's ID is not known until send returns
+		select {
+		case promptID = <-prompt:
+		default:
+		}
+		switch e.EventType {
+		case EventMessagePosted:
+		case EventMessageReply:
+			if promptID == 0 || e.ParentID != promptID {
+				return false
+			}
+		default:
+			return false
+		}
+		select {
+		case answerCh <- e:
+			return true
+		default:
+			return false
+		}
+	})
+	defer cv.conn.removeFilter(id)
+	p, err := send()
+	if err != nil {
+		return nil, err
+	}
+	prompt <- p
+	select {
+	case e := <-answerCh:
+		return e, nil
+	case <-time.After(cv.Timeout):
+		return nil, ErrTimeout
+	case <-cv.conn.closeCh:
+		return nil, ErrConnClosed
+	}
+}
+
+// Wait blocks until the next message from the user is received.
+func (cv *Conversation) Wait() (*Event, error) {
+	return cv.wait(func() (int, error) {
+		return 0, nil
+	})
+}
+
+// Ask posts a question to the room and blocks until the user responds,
+// either by posting a message or by replying to the question.
+func (cv *Conversation) Ask(text string) (*Event, error) {
+	return cv.wait(func() (int, error) {
+		return cv.conn.SendMessage(cv.room, text)
+	})
+}
+
+// AskReply posts a question as a reply to an earlier event and blocks until
+// the user responds.
+func (cv *Conversation) AskReply(e *Event, text string) (*Event, error) {
+	return cv.wait(func() (int, error) {
+		return cv.conn.SendMessage(e.RoomID, format.Reply(e.MessageID, text))
+	})
+}
@@ -0,0 +1,67 @@
+package sechat
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// feed passes events to the connection's filters until one is consumed or the
+// timeout elapses.
+func feed(c *Conn, events ...*Event) {
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		for _, e := range events {
+			if !c.filter(e) {
+				return
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestConversationAsk(t *testing.T) {
+	c := newTestConn(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"id": 100}`)
+	})
+	c.closeCh = make(chan bool)
+	var (
+		cv       = c.NewConversation(1, 2)
+		answerCh = make(chan *Event)
+	)
+	go func() {
+		e, err := cv.Ask("Which branch?")
+		if err != nil {
+			t.Error(err)
+		}
+		answerCh <- e
+	}()
+	feed(
+		c,
+		// A bystander replying to the prompt
+		&Event{EventType: EventMessagePosted, RoomID: 1, UserID: 3, ParentID: 100, MessageID: 1},
+		&Event{EventType: EventMessageReply, RoomID: 1, UserID: 3, ParentID: 100, MessageID: 1},
+		// The user in another room
+		&Event{EventType: EventMessagePosted, RoomID: 4, UserID: 2, MessageID: 2},
+		// The user replying to something else
+		&Event{EventType: EventMessageReply, RoomID: 1, UserID: 2, ParentID: 50, MessageID: 3},
+		// The user's reply to the prompt
+		&Event{EventType: EventMessageReply, RoomID: 1, UserID: 2, ParentID: 100, MessageID: 4},
+	)
+	if e := <-answerCh; e == nil || e.MessageID != 4 {
+		t.Fatalf("%+v", e)
+	}
+}
+
+func TestConversationTimeout(t *testing.T) {
+	c := &Conn{closeCh: make(chan bool)}
+	cv := c.NewConversation(1, 2)
+	cv.Timeout = 10 * time.Millisecond
+	if _, err := cv.Wait(); err != ErrTimeout {
+		t.Fatalf("%v != %v", err, ErrTimeout)
+	}
+	if len(c.filters) != 0 {
+		t.Fatal("filter was not removed")
+	}
+}
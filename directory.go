@@ -0,0 +1,180 @@
+package sechat
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// defaultDirectoryTTL is the length of time that user records remain fresh.
+const defaultDirectoryTTL = 5 * time.Minute
+
+// directoryEntry stores a cached user along with tracking information.
+type directoryEntry struct {
+	user     *User
+	fetched  time.Time
+	lastSeen time.Time
+}
+
+// UserDirectory caches user records and tracks which users are present in each
+// room. Presence is kept up to date using the events received by the
+// connection, so Present() and LastSeen() never make network requests.
+type UserDirectory struct {
+	conn  *Conn
+	mutex sync.Mutex
+	ttl   time.Duration
+	users map[int]*directoryEntry
+	rooms map[int]map[int]struct{}
+}
+
+// newUserDirectory creates an empty directory for the connection.
+func newUserDirectory(c *Conn) *UserDirectory {
+	return &UserDirectory{
+		conn:  c,
+		ttl:   defaultDirectoryTTL,
+		users: map[int]*directoryEntry{},
+		rooms: map[int]map[int]struct{}{},
+	}
+}
+
+// entry returns the entry for the specified user, creating it if necessary.
+// The mutex must be held when calling this method.
+func (d *UserDirectory) entry(user int) *directoryEntry {
+	e, ok := d.users[user]
+	if !ok {
+		e = &directoryEntry{user: &User{ID: user}}
+		d.users[user] = e
+	}
+	return e
+}
+
+// copyUser returns a copy of a user record. Records in the directory are
+// replaced rather than modified, so callers are given copies that they may
+// read (or modify) freely.
+func copyUser(u *User) *User {
+	v := *u
+	return &v
+}
+
+// store adds or replaces the cached record for a user. The mutex must be held
+// when calling this method.
+func (d *UserDirectory) store(u *User) {
+	e := d.entry(u.ID)
+	e.user = copyUser(u)
+	e.fetched = time.Now()
+}
+
+// setName replaces the cached record for a user with one that has the new
+// name. The mutex must be held when calling this method.
+func (d *UserDirectory) setName(e *directoryEntry, name string) {
+	if len(name) != 0 && e.user.Name != name {
+		u := copyUser(e.user)
+		u.Name = name
+		e.user = u
+	}
+}
+
+// SetTTL changes the length of time that cached user records are considered
+// fresh by User().
+func (d *UserDirectory) SetTTL(ttl time.Duration) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.ttl = ttl
+}
+
+// User retrieves extended information for a user, using the cached record if
+// it has not expired.
+func (d *UserDirectory) User(user int) (*User, error) {
+	d.mutex.Lock()
+	if e, ok := d.users[user]; ok && !e.fetched.IsZero() && time.Since(e.fetched) < d.ttl {
+		u := copyUser(e.user)
+		d.mutex.Unlock()
+		return u, nil
+	}
+	d.mutex.Unlock()
+	u, err := d.conn.User(user)
+	if err != nil {
+		return nil, err
+	}
+	d.mutex.Lock()
+	d.store(u)
+	d.mutex.Unlock()
+	return copyUser(u), nil
+}
+
+// Seed retrieves the users currently in a room and records them as present,
+// replacing any presence information for the room. Only partial records are
+// available, so the users aren't considered fetched and User() still retrieves
+// their extended information.
+func (d *UserDirectory) Seed(room int) error {
+	users, err := d.conn.UsersInRoom(room)
+	if err != nil {
+		return err
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	present := map[int]struct{}{}
+	for _, u := range users {
+		e := d.entry(u.ID)
+		if e.fetched.IsZero() {
+			e.user = copyUser(u)
+		} else {
+			d.setName(e, u.Name)
+		}
+		present[u.ID] = struct{}{}
+	}
+	d.rooms[room] = present
+	return nil
+}
+
+// Present returns the users believed to be in a room, ordered by ID. Users
+// that have only been seen in events have just their ID and name filled in.
+func (d *UserDirectory) Present(room int) []*User {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	users := []*User{}
+	for id := range d.rooms[room] {
+		users = append(users, copyUser(d.entry(id).user))
+	}
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].ID < users[j].ID
+	})
+	return users
+}
+
+// LastSeen returns the time of the most recent event involving the user. The
+// zero time is returned if the user has not been seen.
+func (d *UserDirectory) LastSeen(user int) time.Time {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if e, ok := d.users[user]; ok {
+		return e.lastSeen
+	}
+	return time.Time{}
+}
+
+// handle updates the directory using an event received from the server.
+func (d *UserDirectory) handle(ev *Event) {
+	if ev.UserID == 0 {
+		return
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	e := d.entry(ev.UserID)
+	d.setName(e, ev.UserName)
+	if t := time.Unix(int64(ev.TimeStamp), 0); t.After(e.lastSeen) {
+		e.lastSeen = t
+	}
+	switch ev.EventType {
+	case EventUserJoined:
+		if _, ok := d.rooms[ev.RoomID]; !ok {
+			d.rooms[ev.RoomID] = map[int]struct{}{}
+		}
+		d.rooms[ev.RoomID][ev.UserID] = struct{}{}
+	case EventUserLeft:
+		delete(d.rooms[ev.RoomID], ev.UserID)
+	case EventUserNameOrAvatarChanged:
+		// Force the next call to User() to retrieve the new details
+		e.fetched = time.Time{}
+	}
+}
@@ -0,0 +1,130 @@
+package sechat
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newDirectoryTestConn creates a connection whose room 201 contains Alice and
+// Bob. The number of requests for extended user information is counted.
+func newDirectoryTestConn(fetches *int32) *Conn {
+	c := newTestConn(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/rooms/201":
+			fmt.Fprint(w, `<script>$(function() {
+				CHAT.RoomUsers.initPresent([{id: 1, name: "Alice"}, {id: 2, name: "Bob"}]);
+			});</script>`)
+		case "/users/thumbs/1":
+			atomic.AddInt32(fetches, 1)
+			fmt.Fprint(w, `{"id": 1, "name": "Alice", "user_message": "full"}`)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	c.Directory = newUserDirectory(c)
+	return c
+}
+
+func TestDirectorySeed(t *testing.T) {
+	var (
+		fetches int32
+		d       = newDirectoryTestConn(&fetches).Directory
+	)
+	if err := d.Seed(201); err != nil {
+		t.Fatal(err)
+	}
+	users := d.Present(201)
+	if len(users) != 2 || users[0].Name != "Alice" || users[1].Name != "Bob" {
+		t.Fatalf("%+v", users)
+	}
+	// Seeding only provides partial records, so the user must be fetched
+	u, err := d.User(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.UserMessage != "full" || fetches != 1 {
+		t.Fatalf("%+v, %d fetch(es)", u, fetches)
+	}
+	// Seeding again must not replace the full record
+	if err := d.Seed(201); err != nil {
+		t.Fatal(err)
+	}
+	if u, _ := d.User(1); u.UserMessage != "full" || fetches != 1 {
+		t.Fatalf("%+v, %d fetch(es)", u, fetches)
+	}
+}
+
+func TestDirectoryTTL(t *testing.T) {
+	var (
+		fetches int32
+		d       = newDirectoryTestConn(&fetches).Directory
+	)
+	d.User(1)
+	d.User(1)
+	if fetches != 1 {
+		t.Fatalf("%d != 1", fetches)
+	}
+	d.SetTTL(time.Millisecond)
+	time.Sleep(2 * time.Millisecond)
+	d.User(1)
+	if fetches != 2 {
+		t.Fatalf("%d != 2", fetches)
+	}
+}
+
+func TestDirectoryHandle(t *testing.T) {
+	var (
+		fetches int32
+		d       = newDirectoryTestConn(&fetches).Directory
+	)
+	d.User(1)
+	d.handle(&Event{EventType: EventUserJoined, RoomID: 5, UserID: 1, UserName: "Alicia", TimeStamp: 100})
+	d.handle(&Event{EventType: EventUserJoined, RoomID: 5, UserID: 3, UserName: "Carol", TimeStamp: 50})
+	d.handle(&Event{EventType: EventUserLeft, RoomID: 5, UserID: 3})
+	users := d.Present(5)
+	if len(users) != 1 || users[0].Name != "Alicia" {
+		t.Fatalf("%+v", users)
+	}
+	if v := d.LastSeen(1); v.Unix() != 100 {
+		t.Fatalf("%v", v)
+	}
+	if !d.LastSeen(4).IsZero() {
+		t.Fatal("unknown user has been seen")
+	}
+	// A name change invalidates the cached record
+	d.User(1)
+	d.handle(&Event{EventType: EventUserNameOrAvatarChanged, UserID: 1, UserName: "Ali"})
+	d.User(1)
+	if fetches != 2 {
+		t.Fatalf("%d != 2", fetches)
+	}
+}
+
+func TestDirectoryRace(t *testing.T) {
+	var (
+		fetches int32
+		d       = newDirectoryTestConn(&fetches).Directory
+		wg      sync.WaitGroup
+	)
+	d.handle(&Event{EventType: EventUserJoined, RoomID: 5, UserID: 1, UserName: "Alice"})
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 1000; i++ {
+			d.handle(&Event{EventType: EventMessagePosted, RoomID: 5, UserID: 1, UserName: fmt.Sprint(i)})
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 1000; i++ {
+			for _, u := range d.Present(5) {
+				_ = u.Name
+			}
+		}
+	}()
+	wg.Wait()
+}
@@ -48,6 +48,15 @@ To obtain a list of rooms that a user is in, use `User()`:
         }
     }
 
+To avoid repeated requests, `Directory` caches users and tracks presence using the events received:
+
+    if err := c.Directory.Seed(201); err != nil {
+        // handle error
+    }
+    for _, u := range c.Directory.Present(201) {
+        fmt.Printf("User: %s\n", u.Name)
+    }
+
 The `NewRoom()` method can be used to create new rooms:
 
     r, err := c.NewRoom(
@@ -78,6 +87,13 @@ To post a message, simply invoke `Send()`:
         // handle error
     }
 
+Messages that may exceed the length limit can be posted with `SendLong()`, which splits them as needed and returns the IDs of the new messages:
+
+    ids, err := c.SendLong(201, longText)
+    if err != nil {
+        // handle error
+    }
+
 If the message is in response to an earlier event, the `Reply()` method is also available:
 
     if err := c.Reply(e, "Reply to event"); err != nil {
@@ -99,5 +115,12 @@ To upload an image, prepare an `io.Reader` and pass it to `Image()`:
     }
 
 In the example above, `u` is the URL of the newly uploaded image.
+
+To provide a filename or track progress, use `UploadImage()` instead. Images that are already online can be uploaded with `UploadImageFromURL()`:
+
+    u, err := c.UploadImageFromURL("https://example.com/image.png")
+    if err != nil {
+        // handle error
+    }
 */
 package sechat
@@ -3,8 +3,10 @@ package sechat
 import (
 	"regexp"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/PuerkitoBio/goquery"
+	"github.com/nathan-osman/go-sechat/format"
 )
 
 const (
@@ -36,9 +38,12 @@ const (
 )
 
 var mentionRegexp = regexp.MustCompile(
-	`^@[\p{L}\d_]+`,
+	`^@([\p{L}\d_]+)\s*`,
 )
 
+// minMentionLength is the shortest prefix of a name that will ping a user.
+const minMentionLength = 3
+
 // Event represents an individual chat event received from the server. Note
 // that not all event types use all members of this struct.
 type Event struct {
@@ -61,17 +66,66 @@ type Event struct {
 	// A few additional values are precomputed to simplify analysis later on
 	IsMention   bool
 	TextContent string
+	Markdown    string
+}
+
+// mentionMatches determines if a mention refers to a user with the specified
+// display name. Like chat, whitespace in the name is ignored, case is ignored,
+// and a mention matches if it is a prefix of the name at least three
+// characters long.
+func mentionMatches(mention, name string) bool {
+	var (
+		m = strings.ToLower(mention)
+		n = strings.ToLower(format.MentionName(name))
+	)
+	if len(m) == 0 || len(n) == 0 {
+		return false
+	}
+	if m == n {
+		return true
+	}
+	return utf8.RuneCountInString(m) >= minMentionLength &&
+		strings.HasPrefix(n, m)
 }
 
-// precompute fills in the precomputed members.
-func (e *Event) precompute() {
+// precompute fills in the precomputed members. me is the display name of the
+// current user; leading mentions of them are removed from TextContent.
+func (e *Event) precompute(me string) {
 	e.IsMention = e.EventType == EventUserMentioned ||
 		e.EventType == EventMessageReply
 	if d, err := goquery.NewDocumentFromReader(
 		strings.NewReader(e.Content),
 	); err == nil {
-		e.TextContent = strings.TrimSpace(
-			mentionRegexp.ReplaceAllString(d.Text(), ""),
-		)
+		text := strings.TrimSpace(d.Text())
+		for {
+			m := mentionRegexp.FindStringSubmatch(text)
+			if m == nil || !mentionMatches(m[1], me) {
+				break
+			}
+			text = text[len(m[0]):]
+		}
+		e.TextContent = strings.TrimSpace(text)
+		e.Markdown = contentToMarkdown(d)
+	}
+}
+
+// Mentions returns the names of all users pinged in the event's content, in
+// the form that they appear in the message (without the "@").
+func (e *Event) Mentions() []string {
+	return e.Parsed().Mentions
+}
+
+// MentionsMe determines if the event pings the current user of the provided
+// connection, either because the server indicated as much or because one of
+// the mentions in the content matches the user's name.
+func (e *Event) MentionsMe(c *Conn) bool {
+	if e.IsMention {
+		return true
+	}
+	for _, m := range e.Mentions() {
+		if mentionMatches(m, c.UserName()) {
+			return true
+		}
 	}
+	return false
 }
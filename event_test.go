@@ -0,0 +1,51 @@
+package sechat
+
+import (
+	"testing"
+)
+
+func TestMentionMatches(t *testing.T) {
+	for _, test := range []struct {
+		mention string
+		name    string
+		output  bool
+	}{
+		{"JohnDoe", "John Doe", true},
+		{"johndoe", "John Doe", true},
+		{"Joh", "John Doe", true},
+		{"Jo", "John Doe", false},
+		{"Jo", "Jo", true},
+		{"JaneDoe", "John Doe", false},
+		{"JohnDoe2", "John Doe", false},
+		{"", "John Doe", false},
+		{"John", "", false},
+	} {
+		if v := mentionMatches(test.mention, test.name); v != test.output {
+			t.Fatalf("%q, %q: %v != %v", test.mention, test.name, v, test.output)
+		}
+	}
+}
+
+func TestPrecompute(t *testing.T) {
+	for _, test := range []struct {
+		content string
+		output  string
+	}{
+		{"@bot hello", "hello"},
+		{"@Bot @bot hello", "hello"},
+		{"@alice hello", "@alice hello"},
+		{"@bot @alice hello", "@alice hello"},
+		{"hello @bot", "hello @bot"},
+	} {
+		e := &Event{Content: test.content}
+		e.precompute("Bot")
+		if e.TextContent != test.output {
+			t.Fatalf("%q: %q != %q", test.content, e.TextContent, test.output)
+		}
+	}
+	e := &Event{EventType: EventMessageReply}
+	e.precompute("Bot")
+	if !e.IsMention {
+		t.Fatal("reply is not a mention")
+	}
+}
@@ -0,0 +1,48 @@
+package sechat
+
+// eventFilter is invoked for each event before it is delivered. If it returns
+// true, the event has been consumed and is not delivered.
+type eventFilter struct {
+	id int
+	fn func(*Event) bool
+}
+
+// addFilter registers a function that is given each event before it is
+// delivered. The returned ID is used to remove the filter.
+func (c *Conn) addFilter(fn func(*Event) bool) int {
+	c.filterMutex.Lock()
+	defer c.filterMutex.Unlock()
+	c.nextFilterID++
+	c.filters = append(c.filters, &eventFilter{
+		id: c.nextFilterID,
+		fn: fn,
+	})
+	return c.nextFilterID
+}
+
+// removeFilter removes a filter registered with addFilter.
+func (c *Conn) removeFilter(id int) {
+	c.filterMutex.Lock()
+	defer c.filterMutex.Unlock()
+	for i, f := range c.filters {
+		if f.id == id {
+			c.filters = append(c.filters[:i:i], c.filters[i+1:]...)
+			return
+		}
+	}
+}
+
+// filter passes an event to each of the filters in the order they were
+// registered, stopping if one of them consumes it. False is returned if the
+// event should not be delivered.
+func (c *Conn) filter(e *Event) bool {
+	c.filterMutex.Lock()
+	filters := c.filters
+	c.filterMutex.Unlock()
+	for _, f := range filters {
+		if f.fn(e) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,243 @@
+/*
+Package format provides utilities for constructing messages using the markdown
+dialect understood by the Stack Exchange chat network.
+
+Individual elements can be created with the functions in this package:
+
+    text := format.Bold("Build failed:") + " " + format.Link("log", u)
+
+Longer messages are easier to assemble with a Builder:
+
+    b := format.NewBuilder()
+    b.Mention("John Doe").Text(" the build ").Italic("passed")
+    if err := b.Validate(); err != nil {
+        // handle error
+    }
+    c.Send(201, b.String())
+*/
+package format
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// MaxLength is the maximum number of characters permitted in a
+	// single-line message. Multi-line messages are not subject to this limit.
+	MaxLength = 500
+
+	// zeroWidthSpace is inserted to break up syntax that chat recognizes
+	// before markdown is applied (and so can't be escaped with a backslash).
+	zeroWidthSpace = "\u200b"
+)
+
+var (
+	ErrEmpty   = errors.New("message is empty")
+	ErrTooLong = errors.New("single-line message exceeds maximum length")
+
+	// escaper inserts a backslash before characters that have special meaning
+	// in chat markdown.
+	escaper = strings.NewReplacer(
+		`\`, `\\`,
+		"*", `\*`,
+		"_", `\_`,
+		"`", "\\`",
+		"[", `\[`,
+		"]", `\]`,
+		"---", `\-\-\-`,
+		"@", "@" + zeroWidthSpace,
+	)
+
+	// replyPrefixRegexp matches the prefix that marks a message as a reply.
+	replyPrefixRegexp = regexp.MustCompile(`^:\d+\s`)
+
+	// urlEscaper encodes the characters that would end the URL in a link.
+	urlEscaper = strings.NewReplacer(
+		" ", "%20",
+		"(", "%28",
+		")", "%29",
+	)
+)
+
+// Escape ensures that the provided text is displayed literally instead of
+// being interpreted as markdown. It should be used for any text supplied by
+// users. In addition to markdown, pings, a leading reply prefix, and indented
+// lines (which would make the message fixed-font) are neutralized.
+func Escape(text string) string {
+	text = escaper.Replace(text)
+	if replyPrefixRegexp.MatchString(text) {
+		text = zeroWidthSpace + text
+	}
+	lines := strings.Split(text, "\n")
+	for i, l := range lines {
+		if strings.HasPrefix(l, "    ") || strings.HasPrefix(l, "\t") {
+			lines[i] = zeroWidthSpace + l
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Bold displays the text in bold.
+func Bold(text string) string {
+	return fmt.Sprintf("**%s**", text)
+}
+
+// Italic displays the text in italics.
+func Italic(text string) string {
+	return fmt.Sprintf("*%s*", text)
+}
+
+// Code displays the text in a monospace font. Backticks in the text are
+// removed since chat does not provide a way to include them in a code span.
+func Code(text string) string {
+	return fmt.Sprintf("`%s`", strings.Replace(text, "`", "", -1))
+}
+
+// Strike displays the text with a line through it.
+func Strike(text string) string {
+	return fmt.Sprintf("---%s---", text)
+}
+
+// Link creates a link to the specified URL with the provided text. Spaces and
+// parentheses in the URL are percent-encoded so that they don't end the link.
+func Link(text, url string) string {
+	return fmt.Sprintf("[%s](%s)", text, urlEscaper.Replace(url))
+}
+
+// Tag creates a link to the specified tag on the room's parent site.
+func Tag(name string) string {
+	return fmt.Sprintf("[tag:%s]", name)
+}
+
+// MetaTag creates a link to the specified tag on the parent site's meta.
+func MetaTag(name string) string {
+	return fmt.Sprintf("[meta-tag:%s]", name)
+}
+
+// MentionName converts a display name into the form used to ping a user. Chat
+// ignores whitespace when matching names, so it is removed.
+func MentionName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, name)
+}
+
+// Mention pings the user with the specified display name.
+func Mention(name string) string {
+	return "@" + MentionName(name)
+}
+
+// Reply prefixes the text so that it is posted as a reply to the specified
+// message.
+func Reply(message int, text string) string {
+	return fmt.Sprintf(":%d %s", message, text)
+}
+
+// FixedFont displays the text in a monospace font, preserving line breaks.
+// Each line is indented by four spaces, which is how chat identifies a
+// fixed-font message. Markdown within the text is not interpreted.
+func FixedFont(text string) string {
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	for i, l := range lines {
+		lines[i] = "    " + l
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Validate checks that the message can be posted. Single-line messages may
+// not exceed MaxLength characters.
+func Validate(text string) error {
+	if len(strings.TrimSpace(text)) == 0 {
+		return ErrEmpty
+	}
+	if !strings.Contains(text, "\n") && utf8.RuneCountInString(text) > MaxLength {
+		return ErrTooLong
+	}
+	return nil
+}
+
+// Builder assembles a message from individual elements. Each method appends
+// to the message and returns the builder so that calls can be chained.
+type Builder struct {
+	buff bytes.Buffer
+}
+
+// NewBuilder creates a new, empty builder.
+func NewBuilder() *Builder {
+	return &Builder{}
+}
+
+// Raw appends text without escaping it.
+func (b *Builder) Raw(text string) *Builder {
+	b.buff.WriteString(text)
+	return b
+}
+
+// Text appends text, escaping any markdown.
+func (b *Builder) Text(text string) *Builder {
+	return b.Raw(Escape(text))
+}
+
+// Bold appends escaped text in bold.
+func (b *Builder) Bold(text string) *Builder {
+	return b.Raw(Bold(Escape(text)))
+}
+
+// Italic appends escaped text in italics.
+func (b *Builder) Italic(text string) *Builder {
+	return b.Raw(Italic(Escape(text)))
+}
+
+// Code appends text in a monospace font.
+func (b *Builder) Code(text string) *Builder {
+	return b.Raw(Code(text))
+}
+
+// Strike appends escaped text with a line through it.
+func (b *Builder) Strike(text string) *Builder {
+	return b.Raw(Strike(Escape(text)))
+}
+
+// Link appends a link with escaped text.
+func (b *Builder) Link(text, url string) *Builder {
+	return b.Raw(Link(Escape(text), url))
+}
+
+// Tag appends a link to a tag.
+func (b *Builder) Tag(name string) *Builder {
+	return b.Raw(Tag(name))
+}
+
+// Mention appends a ping for the user with the specified display name.
+func (b *Builder) Mention(name string) *Builder {
+	return b.Raw(Mention(name))
+}
+
+// Len returns the length of the message in characters.
+func (b *Builder) Len() int {
+	return utf8.RuneCount(b.buff.Bytes())
+}
+
+// Validate checks that the message can be posted.
+func (b *Builder) Validate() error {
+	return Validate(b.buff.String())
+}
+
+// String returns the message.
+func (b *Builder) String() string {
+	return b.buff.String()
+}
+
+// Reply returns the message prefixed as a reply to the specified message.
+func (b *Builder) Reply(message int) string {
+	return Reply(message, b.buff.String())
+}
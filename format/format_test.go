@@ -0,0 +1,90 @@
+package format
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEscape(t *testing.T) {
+	for _, test := range []struct {
+		text   string
+		output string
+	}{
+		{"plain text", "plain text"},
+		{"*bold*", `\*bold\*`},
+		{"_italic_", `\_italic\_`},
+		{"`code`", "\\`code\\`"},
+		{"[link](url)", `\[link\](url)`},
+		{`back\slash`, `back\\slash`},
+		{"a---b---", `a\-\-\-b\-\-\-`},
+		{"a - b -- c", "a - b -- c"},
+		{"@John hi", "@\u200bJohn hi"},
+		{":123 hi", "\u200b:123 hi"},
+		{"a :123 b", "a :123 b"},
+		{"    code", "\u200b    code"},
+		{"a\n\tb\n  c", "a\n\u200b\tb\n  c"},
+	} {
+		if v := Escape(test.text); v != test.output {
+			t.Fatalf("%q: %q != %q", test.text, v, test.output)
+		}
+	}
+}
+
+func TestElements(t *testing.T) {
+	for _, test := range []struct {
+		text   string
+		output string
+	}{
+		{Bold("a"), "**a**"},
+		{Italic("a"), "*a*"},
+		{Code("a`b"), "`ab`"},
+		{Strike("a"), "---a---"},
+		{Link("a", "http://example.com"), "[a](http://example.com)"},
+		{Link("a", "http://example.com/a (b)"), "[a](http://example.com/a%20%28b%29)"},
+		{Tag("go"), "[tag:go]"},
+		{MetaTag("bug"), "[meta-tag:bug]"},
+		{Mention("John Doe"), "@JohnDoe"},
+		{Reply(123, "a"), ":123 a"},
+		{FixedFont("a\n b\n"), "    a\n     b"},
+	} {
+		if test.text != test.output {
+			t.Fatalf("%q != %q", test.text, test.output)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, test := range []struct {
+		text string
+		err  error
+	}{
+		{"", ErrEmpty},
+		{" \n ", ErrEmpty},
+		{"a", nil},
+		{strings.Repeat("a", MaxLength), nil},
+		{strings.Repeat("a", MaxLength+1), ErrTooLong},
+		{strings.Repeat("é", MaxLength), nil},
+		{strings.Repeat("a", MaxLength) + "\n" + strings.Repeat("a", MaxLength), nil},
+	} {
+		if err := Validate(test.text); err != test.err {
+			t.Fatalf("%q: %v != %v", test.text, err, test.err)
+		}
+	}
+}
+
+func TestBuilder(t *testing.T) {
+	b := NewBuilder()
+	b.Mention("John Doe").Text(" the *build* ").Italic("passed").Code("x")
+	if v := b.String(); v != `@JohnDoe the \*build\* *passed*`+"`x`" {
+		t.Fatalf("%q", v)
+	}
+	if v := b.Len(); v != 34 {
+		t.Fatalf("%d != 34", v)
+	}
+	if v := b.Reply(5); !strings.HasPrefix(v, ":5 @JohnDoe") {
+		t.Fatalf("%q", v)
+	}
+	if err := NewBuilder().Validate(); err != ErrEmpty {
+		t.Fatalf("%v != %v", err, ErrEmpty)
+	}
+}
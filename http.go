@@ -1,21 +1,29 @@
 package sechat
 
 import (
-	"bytes"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"mime/multipart"
 	"net/http"
+	"net/textproto"
 	"net/url"
 	"regexp"
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/PuerkitoBio/goquery"
 )
 
-var conflictRegexp = regexp.MustCompile(`\d+`)
+var (
+	conflictRegexp = regexp.MustCompile(`\d+`)
+
+	// quoteEscaper escapes values in the Content-Disposition header in the same
+	// way as the multipart package.
+	quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+)
 
 // newRequest wraps http.NewRequest, logging the request and allowing the user
 // agent to be customized.
@@ -29,6 +37,29 @@ func (c *Conn) newRequest(method, urlStr string, body io.Reader) (*http.Request,
 	return req, nil
 }
 
+// getDocument is a utility method for retrieving a page from the chat server
+// and parsing it as an HTML document.
+func (c *Conn) getDocument(path string) (*goquery.Document, error) {
+	req, err := c.newRequest(
+		http.MethodGet,
+		fmt.Sprintf("https://chat.stackexchange.com%s", path),
+		nil,
+	)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set(forceRedirect, "1")
+	res, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode >= 400 {
+		return nil, errors.New(res.Status)
+	}
+	return goquery.NewDocumentFromReader(res.Body)
+}
+
 // postForm is a utility method for sending a POST request with form data. The
 // fkey is automatically added to the form data sent. If a 409 Conflict
 // response is received, the request is retried after the specified amount of
@@ -73,24 +104,48 @@ func (c *Conn) postForm(path string, data *url.Values) (*http.Response, error) {
 }
 
 // upload creates and sends a multipart POST request with the specified
-// contents and returns the response.
-func (c *Conn) upload(urlStr, fieldname, filename string, r io.Reader) (*http.Response, error) {
+// contents and returns the response. The body is streamed to the server as it
+// is read from r rather than being buffered in memory.
+func (c *Conn) upload(urlStr, fieldname, filename, contentType string, r io.Reader) (*http.Response, error) {
 	var (
-		body   = &bytes.Buffer{}
-		writer = multipart.NewWriter(body)
+		pr, pw = io.Pipe()
+		writer = multipart.NewWriter(pw)
+		doneCh = make(chan bool)
 	)
-	w, err := writer.CreateFormFile(fieldname, filename)
-	if err != nil {
-		return nil, err
-	}
-	if _, err := io.Copy(w, r); err != nil {
-		return nil, err
-	}
-	writer.Close()
-	req, err := c.newRequest(http.MethodPost, urlStr, body)
+	go func() {
+		defer close(doneCh)
+		h := textproto.MIMEHeader{}
+		h.Set(
+			"Content-Disposition",
+			fmt.Sprintf(
+				`form-data; name="%s"; filename="%s"`,
+				quoteEscaper.Replace(fieldname),
+				quoteEscaper.Replace(filename),
+			),
+		)
+		h.Set("Content-Type", contentType)
+		w, err := writer.CreatePart(h)
+		if err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+		if _, err := io.Copy(w, r); err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+		pw.CloseWithError(writer.Close())
+	}()
+	req, err := c.newRequest(http.MethodPost, urlStr, pr)
 	if err != nil {
+		pr.Close()
+		<-doneCh
 		return nil, err
 	}
 	req.Header.Set("Content-Type", writer.FormDataContentType())
-	return c.client.Do(req)
+	res, err := c.client.Do(req)
+	// Ensure the goroutine exits if the request failed before reading the
+	// entire body and wait for it so that r is no longer in use
+	pr.Close()
+	<-doneCh
+	return res, err
 }
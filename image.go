@@ -1,20 +1,85 @@
 package sechat
 
 import (
+	"bytes"
 	"errors"
 	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
 )
 
-// Image uploads an image and returns its new URL.
-func (c *Conn) Image(r io.Reader) (string, error) {
-	res, err := c.upload(
-		"https://chat.stackexchange.com/upload/image",
-		"filename",
-		"untitled",
-		r,
-	)
-	if err != nil {
-		return "", err
+// MaxImageSize is the largest image (in bytes) that the chat server accepts.
+const MaxImageSize = 2 * 1024 * 1024
+
+var (
+	ErrImageTooLarge = errors.New("image exceeds maximum size")
+	ErrImageFormat   = errors.New("unsupported image format")
+
+	// imageTypes maps the image formats accepted by the chat server to the
+	// extension used when no filename is provided.
+	imageTypes = map[string]string{
+		"image/gif":  ".gif",
+		"image/jpeg": ".jpg",
+		"image/png":  ".png",
+	}
+)
+
+// UploadOptions provides additional parameters for an image upload. All fields
+// are optional.
+type UploadOptions struct {
+	// Filename is sent to the server and used to guess the content type if it
+	// cannot be detected from the data
+	Filename string
+
+	// Size allows the upload to be rejected before any data is sent; if it is
+	// zero, the size is checked as the data is sent instead
+	Size int64
+
+	// Progress is invoked with the total number of bytes sent so far
+	Progress func(sent int64)
+}
+
+// uploadReader tracks the number of bytes read, reporting progress and failing
+// once the maximum image size has been exceeded.
+type uploadReader struct {
+	r        io.Reader
+	sent     int64
+	progress func(sent int64)
+}
+
+// Read reads from the underlying reader and updates the count.
+func (u *uploadReader) Read(p []byte) (int, error) {
+	n, err := u.r.Read(p)
+	u.sent += int64(n)
+	if u.sent > MaxImageSize {
+		return n, ErrImageTooLarge
+	}
+	if n != 0 && u.progress != nil {
+		u.progress(u.sent)
+	}
+	return n, err
+}
+
+// detectImageType determines the content type of an image from its first few
+// bytes, falling back to the filename extension.
+func detectImageType(head []byte, filename string) (string, error) {
+	t := http.DetectContentType(head)
+	if _, ok := imageTypes[t]; !ok {
+		t, _, _ = mime.ParseMediaType(mime.TypeByExtension(path.Ext(filename)))
+	}
+	if _, ok := imageTypes[t]; !ok {
+		return "", ErrImageFormat
+	}
+	return t, nil
+}
+
+// parseUploadResponse extracts the URL of the uploaded image from the response
+// returned by the upload endpoint.
+func (c *Conn) parseUploadResponse(res *http.Response) (string, error) {
+	if res.StatusCode >= 400 {
+		return "", errors.New(res.Status)
 	}
 	program, err := c.parseJavaScript(res)
 	if err != nil {
@@ -43,3 +108,67 @@ func (c *Conn) Image(r io.Reader) (string, error) {
 	}
 	return upURL, nil
 }
+
+// Image uploads an image and returns its new URL.
+func (c *Conn) Image(r io.Reader) (string, error) {
+	return c.UploadImage(r, nil)
+}
+
+// UploadImage uploads an image using the provided options and returns its new
+// URL. The format is checked before the upload begins and the data is
+// streamed to the server as it is read.
+func (c *Conn) UploadImage(r io.Reader, opts *UploadOptions) (string, error) {
+	if opts == nil {
+		opts = &UploadOptions{}
+	}
+	if opts.Size > MaxImageSize {
+		return "", ErrImageTooLarge
+	}
+	head := make([]byte, 512)
+	n, err := io.ReadFull(r, head)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+	head = head[:n]
+	contentType, err := detectImageType(head, opts.Filename)
+	if err != nil {
+		return "", err
+	}
+	filename := opts.Filename
+	if len(filename) == 0 {
+		filename = "untitled" + imageTypes[contentType]
+	}
+	ur := &uploadReader{
+		r:        io.MultiReader(bytes.NewReader(head), r),
+		progress: opts.Progress,
+	}
+	res, err := c.upload(
+		"https://chat.stackexchange.com/upload/image",
+		"filename",
+		filename,
+		contentType,
+		ur,
+	)
+	// The error from the reader is wrapped by the HTTP client (if it is
+	// returned at all) so check the count directly
+	if ur.sent > MaxImageSize {
+		return "", ErrImageTooLarge
+	}
+	if err != nil {
+		return "", err
+	}
+	return c.parseUploadResponse(res)
+}
+
+// UploadImageFromURL instructs the chat server to retrieve the image at the
+// specified URL and upload it. The new URL of the image is returned.
+func (c *Conn) UploadImageFromURL(urlStr string) (string, error) {
+	res, err := c.postForm(
+		"/upload/image",
+		&url.Values{"upload-url": {urlStr}},
+	)
+	if err != nil {
+		return "", err
+	}
+	return c.parseUploadResponse(res)
+}
@@ -0,0 +1,91 @@
+package sechat
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"testing"
+)
+
+// pngHeader is the signature at the start of every PNG file.
+var pngHeader = []byte("\x89PNG\r\n\x1a\n")
+
+func TestDetectImageType(t *testing.T) {
+	for _, test := range []struct {
+		head     []byte
+		filename string
+		output   string
+		err      error
+	}{
+		{pngHeader, "", "image/png", nil},
+		{[]byte("GIF89a"), "", "image/gif", nil},
+		{[]byte("\xff\xd8\xff\xe0"), "a.png", "image/jpeg", nil},
+		{[]byte("unknown"), "photo.JPG", "image/jpeg", nil},
+		{[]byte("unknown"), "photo.bmp", "", ErrImageFormat},
+		{[]byte("<html>"), "", "", ErrImageFormat},
+		{[]byte{}, "", "", ErrImageFormat},
+	} {
+		v, err := detectImageType(test.head, test.filename)
+		if v != test.output || err != test.err {
+			t.Fatalf("%q, %q: %q, %v", test.head, test.filename, v, err)
+		}
+	}
+}
+
+// newUploadTestConn creates a connection that accepts uploads, responding
+// with the provided script.
+func newUploadTestConn(t *testing.T, script string) *Conn {
+	return newTestConn(func(w http.ResponseWriter, r *http.Request) {
+		f, h, err := r.FormFile("filename")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer f.Close()
+		io.Copy(ioutil.Discard, f)
+		if h.Header.Get("Content-Type") != "image/png" {
+			t.Errorf("unexpected content type %s", h.Header.Get("Content-Type"))
+		}
+		fmt.Fprintf(w, "<script>%s</script>", script)
+	})
+}
+
+func TestUploadImage(t *testing.T) {
+	c := newUploadTestConn(t, `var result = 'https://i.stack.imgur.com/a.png'; var error = null;`)
+	var sent int64
+	u, err := c.UploadImage(
+		bytes.NewReader(append(pngHeader, make([]byte, 1000)...)),
+		&UploadOptions{Progress: func(n int64) { sent = n }},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u != "https://i.stack.imgur.com/a.png" || sent != 1008 {
+		t.Fatalf("%q, %d", u, sent)
+	}
+	c = newUploadTestConn(t, `var result = null; var error = 'Failed to upload';`)
+	if _, err := c.Image(bytes.NewReader(pngHeader)); err == nil || err.Error() != "Failed to upload" {
+		t.Fatalf("%v", err)
+	}
+}
+
+func TestUploadImageTooLarge(t *testing.T) {
+	c := newUploadTestConn(t, `var result = 'https://i.stack.imgur.com/a.png';`)
+	if _, err := c.UploadImage(
+		bytes.NewReader(pngHeader),
+		&UploadOptions{Size: MaxImageSize + 1},
+	); err != ErrImageTooLarge {
+		t.Fatalf("%v != %v", err, ErrImageTooLarge)
+	}
+	if _, err := c.UploadImage(
+		bytes.NewReader(append(pngHeader, make([]byte, MaxImageSize)...)),
+		nil,
+	); err != ErrImageTooLarge {
+		t.Fatalf("%v != %v", err, ErrImageTooLarge)
+	}
+	if _, err := c.UploadImage(bytes.NewReader([]byte("not an image")), nil); err != ErrImageFormat {
+		t.Fatalf("%v != %v", err, ErrImageFormat)
+	}
+}
@@ -0,0 +1,53 @@
+// Package config loads the credentials used by the commands in this
+// repository.
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+var ErrCredentials = errors.New("credentials not provided (set SECHAT_EMAIL and SECHAT_PASSWORD or create a config file)")
+
+// Config stores the credentials used to log in.
+type Config struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+// DefaultPath returns the path to the default config file.
+func DefaultPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "sechat", "config.json")
+}
+
+// Load reads credentials from the environment, falling back to the config file
+// at the specified path.
+func Load(path string) (*Config, error) {
+	cfg := &Config{
+		Email:    os.Getenv("SECHAT_EMAIL"),
+		Password: os.Getenv("SECHAT_PASSWORD"),
+	}
+	if len(cfg.Email) == 0 || len(cfg.Password) == 0 {
+		f, err := os.Open(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil, ErrCredentials
+			}
+			return nil, err
+		}
+		defer f.Close()
+		if err := json.NewDecoder(f).Decode(cfg); err != nil {
+			return nil, err
+		}
+	}
+	if len(cfg.Email) == 0 || len(cfg.Password) == 0 {
+		return nil, ErrCredentials
+	}
+	return cfg, nil
+}
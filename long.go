@@ -0,0 +1,193 @@
+package sechat
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/nathan-osman/go-sechat/format"
+)
+
+// maxMultiLineLength is the number of characters included in each part of a
+// long multi-line message. Chat collapses long multi-line messages and rejects
+// extremely long ones, so they are split into parts of a reasonable size.
+const maxMultiLineLength = 5000
+
+var ErrTooManyMessages = errors.New("text requires too many messages")
+
+// replyRegexp matches the prefix that marks a message as a reply.
+var replyRegexp = regexp.MustCompile(`^:\d+ `)
+
+// SendLongOptions controls how SendLongWithOptions splits a message.
+type SendLongOptions struct {
+	// MaxMessages limits the number of messages posted; zero means no limit.
+	// If Paste is nil and the text requires more messages, nothing is posted
+	// and ErrTooManyMessages is returned
+	MaxMessages int
+
+	// Paste uploads text and returns a URL where it can be viewed; if set,
+	// any text that doesn't fit in MaxMessages messages is uploaded and a link
+	// to it is posted as the last message
+	Paste func(text string) (string, error)
+}
+
+// splitWords splits a single line into parts of at most n characters, breaking
+// at the last space before the limit where possible.
+func splitWords(text string, n int) []string {
+	parts := []string{}
+	for utf8.RuneCountInString(text) > n {
+		var (
+			runes = []rune(text)
+			i     = strings.LastIndex(string(runes[:n+1]), " ")
+		)
+		if i <= 0 {
+			i = len(string(runes[:n]))
+		}
+		parts = append(parts, strings.TrimSpace(text[:i]))
+		text = strings.TrimSpace(text[i:])
+	}
+	if len(text) != 0 {
+		parts = append(parts, text)
+	}
+	return parts
+}
+
+// splitLines splits text into parts of at most n characters, breaking between
+// lines where possible. Lines that are too long are split at word boundaries.
+func splitLines(text string, n int) []string {
+	var (
+		parts = []string{}
+		cur   = []string{}
+		size  = 0
+	)
+	flush := func() {
+		if len(cur) != 0 {
+			parts = append(parts, strings.Join(cur, "\n"))
+			cur, size = []string{}, 0
+		}
+	}
+	for _, l := range strings.Split(text, "\n") {
+		for _, w := range splitWords(l, n) {
+			wLen := utf8.RuneCountInString(w) + 1
+			if size+wLen > n {
+				flush()
+			}
+			cur = append(cur, w)
+			size += wLen
+		}
+		if len(l) == 0 {
+			cur = append(cur, "")
+			size++
+		}
+	}
+	flush()
+	return parts
+}
+
+// isFixedFont determines if every line of text is indented by four spaces,
+// which means that it is already formatted in fixed-font.
+func isFixedFont(text string) bool {
+	for _, l := range strings.Split(text, "\n") {
+		if len(l) != 0 && !strings.HasPrefix(l, "    ") {
+			return false
+		}
+	}
+	return true
+}
+
+// splitLong divides text into the individual messages that will be posted.
+// Multi-line text is posted in fixed-font so that its formatting is kept; text
+// that is already fixed-font has its indentation removed before splitting so
+// that it is not indented twice. A reply prefix is kept on the first message.
+func splitLong(text string) []string {
+	text = strings.TrimRight(text, "\n")
+	prefix := replyRegexp.FindString(text)
+	text = text[len(prefix):]
+	var parts []string
+	if !strings.Contains(text, "\n") {
+		parts = splitWords(text, format.MaxLength-len(prefix))
+	} else {
+		if isFixedFont(text) {
+			lines := strings.Split(text, "\n")
+			for i, l := range lines {
+				lines[i] = strings.TrimPrefix(l, "    ")
+			}
+			text = strings.Join(lines, "\n")
+		}
+		parts = splitLines(text, maxMultiLineLength)
+		for i, p := range parts {
+			parts[i] = format.FixedFont(p)
+		}
+	}
+	if len(parts) != 0 {
+		parts[0] = prefix + parts[0]
+	}
+	return parts
+}
+
+// SendLong posts a message of any length to the specified room, splitting it
+// into multiple messages if necessary. The IDs of the messages are returned in
+// the order that they were posted.
+func (c *Conn) SendLong(room int, text string) ([]int, error) {
+	return c.SendLongWithOptions(room, text, nil)
+}
+
+// SendLongWithOptions is identical to SendLong but allows the number of
+// messages to be limited, with the remainder of the text uploaded elsewhere.
+func (c *Conn) SendLongWithOptions(room int, text string, opts *SendLongOptions) ([]int, error) {
+	if opts == nil {
+		opts = &SendLongOptions{}
+	}
+	var (
+		parts    = splitLong(text)
+		overflow string
+		prefix   string
+	)
+	if opts.MaxMessages > 0 && len(parts) > opts.MaxMessages {
+		if opts.Paste == nil {
+			return []int{}, ErrTooManyMessages
+		}
+		// Reserve the last message for the link to the remaining text; the
+		// fixed-font indentation is removed from the uploaded text and, if
+		// the link is the only message, the reply prefix is moved to it
+		rest := parts[opts.MaxMessages-1:]
+		if opts.MaxMessages == 1 {
+			prefix = replyRegexp.FindString(rest[0])
+			rest[0] = rest[0][len(prefix):]
+		}
+		for i, p := range rest {
+			rest[i] = strings.Replace(strings.TrimPrefix(p, "    "), "\n    ", "\n", -1)
+		}
+		sep := " "
+		if strings.Contains(text, "\n") {
+			sep = "\n"
+		}
+		overflow = strings.Join(rest, sep)
+		parts = parts[:opts.MaxMessages-1]
+	}
+	ids := []int{}
+	for _, p := range parts {
+		id, err := c.SendMessage(room, p)
+		if err != nil {
+			return ids, err
+		}
+		ids = append(ids, id)
+	}
+	if len(overflow) != 0 {
+		u, err := opts.Paste(overflow)
+		if err != nil {
+			return ids, err
+		}
+		id, err := c.SendMessage(
+			room,
+			prefix+fmt.Sprintf("(continued: %s)", format.Link("full text", u)),
+		)
+		if err != nil {
+			return ids, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
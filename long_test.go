@@ -0,0 +1,84 @@
+package sechat
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/nathan-osman/go-sechat/format"
+)
+
+func TestSplitWords(t *testing.T) {
+	for _, test := range []struct {
+		text   string
+		n      int
+		output []string
+	}{
+		{"", 5, []string{}},
+		{"abc", 5, []string{"abc"}},
+		{"abc def", 5, []string{"abc", "def"}},
+		{"abcdefgh", 5, []string{"abcde", "fgh"}},
+		{"ab cd ef", 5, []string{"ab cd", "ef"}},
+		{"ééé ééé", 5, []string{"ééé", "ééé"}},
+	} {
+		if v := splitWords(test.text, test.n); !reflect.DeepEqual(v, test.output) {
+			t.Fatalf("%q: %q != %q", test.text, v, test.output)
+		}
+	}
+}
+
+func TestSplitLines(t *testing.T) {
+	for _, test := range []struct {
+		text   string
+		n      int
+		output []string
+	}{
+		{"a\nb", 10, []string{"a\nb"}},
+		{"aaaa\nbbbb\ncccc", 10, []string{"aaaa\nbbbb", "cccc"}},
+		{"a\n\nb", 10, []string{"a\n\nb"}},
+		{"aaaa bbbb cccc", 10, []string{"aaaa bbbb", "cccc"}},
+	} {
+		if v := splitLines(test.text, test.n); !reflect.DeepEqual(v, test.output) {
+			t.Fatalf("%q: %q != %q", test.text, v, test.output)
+		}
+	}
+}
+
+func TestSplitLong(t *testing.T) {
+	for _, test := range []struct {
+		text   string
+		output []string
+	}{
+		{"hello", []string{"hello"}},
+		{"a\nb\n", []string{"    a\n    b"}},
+		{"    a\n        b", []string{"    a\n        b"}},
+		{":123 build failed", []string{":123 build failed"}},
+		{":123 build failed:\nstep 3", []string{":123     build failed:\n    step 3"}},
+		{":123     a\n    b", []string{":123     a\n    b"}},
+	} {
+		if v := splitLong(test.text); !reflect.DeepEqual(v, test.output) {
+			t.Fatalf("%q: %q != %q", test.text, v, test.output)
+		}
+	}
+	parts := splitLong(":123 " + strings.Repeat("a ", format.MaxLength))
+	if len(parts) != 3 || !strings.HasPrefix(parts[0], ":123 a") {
+		t.Fatalf("%q", parts)
+	}
+	for _, p := range parts {
+		if err := format.Validate(p); err != nil {
+			t.Fatalf("%q: %s", p, err)
+		}
+	}
+}
+
+func TestSendLongMaxMessages(t *testing.T) {
+	c := &Conn{}
+	ids, err := c.SendLongWithOptions(
+		1,
+		strings.Repeat("a ", format.MaxLength),
+		&SendLongOptions{MaxMessages: 1},
+	)
+	if err != ErrTooManyMessages || len(ids) != 0 {
+		t.Fatalf("%v, %v", ids, err)
+	}
+}
@@ -66,11 +66,15 @@ func (c *Conn) run(ch chan<- *Event) {
 				}
 				for _, e := range room.Events {
 					if _, exists := msgIDs[e.ID]; !exists {
-						e.precompute()
-						// Use non-blocking send
-						select {
-						case ch <- e:
-						default:
+						e.precompute(c.UserName())
+						c.Directory.handle(e)
+						c.store(e)
+						if c.filter(e) {
+							// Use non-blocking send
+							select {
+							case ch <- e:
+							default:
+							}
 						}
 						msgIDs[e.ID] = struct{}{}
 					}
@@ -0,0 +1,177 @@
+package sechat
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/nathan-osman/go-sechat/format"
+)
+
+// anyMentionRegexp matches a mention of a user anywhere in a message.
+var anyMentionRegexp = regexp.MustCompile(`(?:^|[^\p{L}\d_])@([\p{L}\d_]+)`)
+
+// Link describes a link within a message.
+type Link struct {
+	Text string
+	URL  string
+}
+
+// Onebox describes content that chat has expanded from a URL, such as an image
+// or a question from one of the sites.
+type Onebox struct {
+	Type string
+	URL  string
+}
+
+// ParsedContent provides a structured view of the content of a message.
+type ParsedContent struct {
+	Links    []*Link
+	Mentions []string
+	Tags     []string
+	Onebox   *Onebox
+}
+
+// markdownConverter converts the HTML generated by chat back into chat
+// markdown.
+type markdownConverter struct {
+	buff bytes.Buffer
+}
+
+// wrap converts the children of a node and surrounds them with the provided
+// delimiters.
+func (m *markdownConverter) wrap(s *goquery.Selection, open, close string) {
+	m.buff.WriteString(open)
+	m.children(s)
+	m.buff.WriteString(close)
+}
+
+// children converts each of the children of a node.
+func (m *markdownConverter) children(s *goquery.Selection) {
+	s.Contents().Each(func(i int, c *goquery.Selection) {
+		m.node(c)
+	})
+}
+
+// node converts an individual node.
+func (m *markdownConverter) node(s *goquery.Selection) {
+	switch goquery.NodeName(s) {
+	case "#text":
+		m.buff.WriteString(format.Escape(s.Text()))
+	case "b", "strong":
+		m.wrap(s, "**", "**")
+	case "i", "em":
+		m.wrap(s, "*", "*")
+	case "strike", "del", "s":
+		m.wrap(s, "---", "---")
+	case "code":
+		m.buff.WriteString(format.Code(s.Text()))
+	case "pre":
+		m.buff.WriteString(format.FixedFont(s.Text()))
+	case "br":
+		m.buff.WriteString("\n")
+	case "a":
+		m.link(s)
+	case "div":
+		switch {
+		case s.HasClass("onebox"):
+			m.buff.WriteString(oneboxURL(s))
+		case s.HasClass("quote"):
+			inner := &markdownConverter{}
+			inner.children(s)
+			for i, l := range strings.Split(strings.TrimSpace(inner.buff.String()), "\n") {
+				if i != 0 {
+					m.buff.WriteString("\n")
+				}
+				m.buff.WriteString("> " + l)
+			}
+		default:
+			m.children(s)
+		}
+	default:
+		m.children(s)
+	}
+}
+
+// link converts a link, which may refer to a tag.
+func (m *markdownConverter) link(s *goquery.Selection) {
+	if tag := s.Find(".ob-post-tag"); tag.Length() != 0 {
+		if strings.Contains(s.AttrOr("href", ""), "://meta.") {
+			m.buff.WriteString(format.MetaTag(tag.Text()))
+		} else {
+			m.buff.WriteString(format.Tag(tag.Text()))
+		}
+		return
+	}
+	var (
+		href  = s.AttrOr("href", "")
+		inner = &markdownConverter{}
+	)
+	inner.children(s)
+	if text := inner.buff.String(); text == format.Escape(href) {
+		m.buff.WriteString(href)
+	} else {
+		m.buff.WriteString(format.Link(text, href))
+	}
+}
+
+// oneboxURL returns the URL that was expanded into a onebox.
+func oneboxURL(s *goquery.Selection) string {
+	return s.Find("a").First().AttrOr("href", "")
+}
+
+// contentToMarkdown converts the HTML content of a message to markdown.
+func contentToMarkdown(doc *goquery.Document) string {
+	m := &markdownConverter{}
+	m.children(doc.Find("body"))
+	// Leading spaces are significant for fixed-font messages
+	return strings.Trim(strings.TrimRightFunc(m.buff.String(), unicode.IsSpace), "\n")
+}
+
+// parseContent extracts the structured view of the content of a message.
+func parseContent(doc *goquery.Document) *ParsedContent {
+	p := &ParsedContent{
+		Links:    []*Link{},
+		Mentions: []string{},
+		Tags:     []string{},
+	}
+	if ob := doc.Find(".onebox").First(); ob.Length() != 0 {
+		o := &Onebox{URL: oneboxURL(ob)}
+		for _, c := range strings.Fields(ob.AttrOr("class", "")) {
+			if strings.HasPrefix(c, "ob-") {
+				o.Type = strings.TrimPrefix(c, "ob-")
+				break
+			}
+		}
+		p.Onebox = o
+	}
+	doc.Find("a").Each(func(i int, s *goquery.Selection) {
+		if s.ParentsFiltered(".onebox").Length() != 0 {
+			return
+		}
+		if tag := s.Find(".ob-post-tag"); tag.Length() != 0 {
+			p.Tags = append(p.Tags, tag.Text())
+			return
+		}
+		p.Links = append(p.Links, &Link{
+			Text: strings.TrimSpace(s.Text()),
+			URL:  s.AttrOr("href", ""),
+		})
+	})
+	for _, m := range anyMentionRegexp.FindAllStringSubmatch(doc.Text(), -1) {
+		p.Mentions = append(p.Mentions, m[1])
+	}
+	return p
+}
+
+// Parsed returns a structured view of the links, mentions, tags, and onebox in
+// the content of the event.
+func (e *Event) Parsed() *ParsedContent {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(e.Content))
+	if err != nil {
+		return &ParsedContent{}
+	}
+	return parseContent(doc)
+}
@@ -0,0 +1,65 @@
+package sechat
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestContentToMarkdown(t *testing.T) {
+	for _, test := range []struct {
+		html   string
+		output string
+	}{
+		{"plain text", "plain text"},
+		{"<b>bold</b> and <i>italic</i>", "**bold** and *italic*"},
+		{"<strike>gone</strike>", "---gone---"},
+		{"<code>x := 1</code>", "`x := 1`"},
+		{"2 * 3", `2 \* 3`},
+		{`<a href="http://example.com">site</a>`, "[site](http://example.com)"},
+		{`<a href="http://example.com">http://example.com</a>`, "http://example.com"},
+		{`<a href="https://stackoverflow.com/tags/go"><span class="ob-post-tag">go</span></a>`, "[tag:go]"},
+		{`<a href="https://meta.stackoverflow.com/tags/bug"><span class="ob-post-tag">bug</span></a>`, "[meta-tag:bug]"},
+		{"<pre class='full'>a\n  b</pre>", "    a\n      b"},
+		{"<div class='full'>a<br>b</div>", "a\nb"},
+		{"<div class='quote full'>quoted<br>text</div>", "> quoted\n> text"},
+		{`<div class="onebox ob-image"><a href="http://i.example.com/a.png"><img src="x"></a></div>`, "http://i.example.com/a.png"},
+	} {
+		d, err := goquery.NewDocumentFromReader(strings.NewReader(test.html))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v := contentToMarkdown(d); v != test.output {
+			t.Fatalf("%q: %q != %q", test.html, v, test.output)
+		}
+	}
+}
+
+func TestParsed(t *testing.T) {
+	e := &Event{
+		Content: `@Alice see <a href="http://example.com">this</a> ` +
+			`<a href="https://stackoverflow.com/tags/go"><span class="ob-post-tag">go</span></a> ` +
+			`cc @Bob_2 (not email@example.com)`,
+	}
+	p := e.Parsed()
+	if len(p.Links) != 1 || p.Links[0].Text != "this" || p.Links[0].URL != "http://example.com" {
+		t.Fatalf("%+v", p.Links)
+	}
+	if !reflect.DeepEqual(p.Tags, []string{"go"}) {
+		t.Fatalf("%q", p.Tags)
+	}
+	if !reflect.DeepEqual(p.Mentions, []string{"Alice", "Bob_2"}) {
+		t.Fatalf("%q", p.Mentions)
+	}
+	if p.Onebox != nil {
+		t.Fatalf("%+v", p.Onebox)
+	}
+	e = &Event{
+		Content: `<div class="onebox ob-youtube"><a href="https://youtu.be/x">video</a></div>`,
+	}
+	if p := e.Parsed(); p.Onebox == nil || p.Onebox.Type != "youtube" || len(p.Links) != 0 {
+		t.Fatalf("%+v", p)
+	}
+}
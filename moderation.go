@@ -0,0 +1,97 @@
+package sechat
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// KickMute removes a user from a room and prevents them from returning for a
+// short period of time. The change is confirmed by an EventUserSuspended event.
+func (c *Conn) KickMute(room, user int) error {
+	_, err := c.postForm(
+		fmt.Sprintf("/rooms/kickmute/%d", room),
+		&url.Values{"userId": {strconv.Itoa(user)}},
+	)
+	return err
+}
+
+// Unmute lifts a kick-mute, allowing the user to return to the room.
+func (c *Conn) Unmute(room, user int) error {
+	_, err := c.postForm(
+		fmt.Sprintf("/rooms/unmute/%d", room),
+		&url.Values{"userId": {strconv.Itoa(user)}},
+	)
+	return err
+}
+
+// Flag flags the specified message. If reason is empty, the message is flagged
+// as spam or offensive; otherwise it is flagged for moderator attention with
+// the provided reason.
+func (c *Conn) Flag(message int, reason string) error {
+	if len(reason) == 0 {
+		_, err := c.postForm(
+			fmt.Sprintf("/messages/%d/flag", message),
+			&url.Values{},
+		)
+		return err
+	}
+	_, err := c.postForm(
+		fmt.Sprintf("/messages/%d/modflag", message),
+		&url.Values{"reason": {reason}},
+	)
+	return err
+}
+
+// MoveMessages moves the specified messages from one room to another. The
+// move is confirmed by an EventMessageMovedOut event in the original room and
+// an EventMessageMovedIn event in the destination room.
+func (c *Conn) MoveMessages(fromRoom int, messages []int, toRoom int) error {
+	messagesStr := make([]string, len(messages))
+	for i, message := range messages {
+		messagesStr[i] = strconv.Itoa(message)
+	}
+	_, err := c.postForm(
+		fmt.Sprintf("/admin/movePosts/%d", fromRoom),
+		&url.Values{
+			"ids": {strings.Join(messagesStr, ",")},
+			"to":  {strconv.Itoa(toRoom)},
+		},
+	)
+	return err
+}
+
+// SuspendedUsers retrieves the users that are currently kick-muted or
+// suspended in the specified room. Only the first few fields in the User
+// struct are filled in.
+func (c *Conn) SuspendedUsers(room int) ([]*User, error) {
+	res, err := c.postForm(
+		fmt.Sprintf("/rooms/suspended/%d", room),
+		&url.Values{},
+	)
+	if err != nil {
+		return nil, err
+	}
+	var v struct {
+		Users []*User `json:"users"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&v); err != nil {
+		return nil, err
+	}
+	return v.Users, nil
+}
+
+// IsMove indicates whether the event confirms that a message was moved into or
+// out of a room.
+func (e *Event) IsMove() bool {
+	return e.EventType == EventMessageMovedOut ||
+		e.EventType == EventMessageMovedIn
+}
+
+// IsSuspension indicates whether the event confirms that a user was kick-muted
+// or suspended. The affected user is stored in TargetUserID.
+func (e *Event) IsSuspension() bool {
+	return e.EventType == EventUserSuspended
+}
@@ -0,0 +1,149 @@
+package sechat
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// UserProfile provides the information shown on a user's chat profile in
+// addition to the fields in User.
+type UserProfile struct {
+	*User
+	AboutMe      string
+	ParentURL    string
+	MessageCount int
+	RoomsOwned   []*Room
+	Recent       []*Event
+}
+
+// profileValues extracts the key/value table from a profile page. Keys are
+// normalized to lowercase.
+func profileValues(doc *goquery.Document) map[string]*goquery.Selection {
+	values := map[string]*goquery.Selection{}
+	doc.Find(".user-keycell").Each(func(i int, s *goquery.Selection) {
+		k := strings.ToLower(strings.TrimSpace(s.Text()))
+		values[k] = s.NextFiltered(".user-valuecell")
+	})
+	return values
+}
+
+// parseCount converts a number that may contain thousands separators.
+func parseCount(s string) int {
+	return atoi(strings.Replace(strings.TrimSpace(s), ",", "", -1))
+}
+
+// UserProfile retrieves the complete profile for a user, including the
+// fields in User that are only partially populated by the other methods.
+func (c *Conn) UserProfile(user int) (*UserProfile, error) {
+	u, err := c.User(user)
+	if err != nil {
+		return nil, err
+	}
+	p := &UserProfile{
+		User:       u,
+		RoomsOwned: []*Room{},
+	}
+	doc, err := c.getDocument(fmt.Sprintf("/users/%d", user))
+	if err != nil {
+		return nil, err
+	}
+	values := profileValues(doc)
+	if s, ok := values["about"]; ok {
+		p.AboutMe = strings.TrimSpace(s.Text())
+	}
+	if s, ok := values["messages"]; ok {
+		p.MessageCount = parseCount(s.Text())
+	}
+	if s, ok := values["parent user"]; ok {
+		a := s.Find("a").First()
+		p.ParentURL = a.AttrOr("href", "")
+		if len(u.ProfileURL) == 0 {
+			u.ProfileURL = p.ParentURL
+		}
+		if len(u.Host) == 0 {
+			if v, err := url.Parse(p.ParentURL); err == nil {
+				u.Host = v.Host
+			}
+		}
+		if u.Site == nil {
+			u.Site = &Site{
+				Icon:    a.Find("img").AttrOr("src", ""),
+				Caption: a.AttrOr("title", ""),
+			}
+		}
+	}
+	if len(u.UserMessage) == 0 {
+		u.UserMessage = strings.TrimSpace(doc.Find(".user-status").Text())
+	}
+	if s, ok := values["issues"]; ok && u.Issues == 0 {
+		u.Issues = parseCount(s.Text())
+	}
+	doc.Find("#user-owned-rooms .roomcard").Each(func(i int, s *goquery.Selection) {
+		if r := parseRoomCard(s); r != nil {
+			p.RoomsOwned = append(p.RoomsOwned, r)
+		}
+	})
+	recent, err := c.getDocument(fmt.Sprintf("/users/%d?tab=recent", user))
+	if err != nil {
+		return nil, err
+	}
+	p.Recent = []*Event{}
+	recent.Find(".monologue .message").Each(func(i int, s *goquery.Selection) {
+		e := parseTranscriptMessage(s)
+		if e == nil {
+			return
+		}
+		e.UserID = u.ID
+		e.UserName = u.Name
+		e.precompute(c.UserName())
+		p.Recent = append(p.Recent, e)
+	})
+	return p, nil
+}
+
+// SearchUsers finds users whose names contain the provided text (ignoring
+// case). If room is non-zero, only users currently in that room are searched.
+// Only the first few fields in the User struct are filled in.
+func (c *Conn) SearchUsers(name string, room int) ([]*User, error) {
+	if room != 0 {
+		users, err := c.UsersInRoom(room)
+		if err != nil {
+			return nil, err
+		}
+		var (
+			lower   = strings.ToLower(name)
+			matches = []*User{}
+		)
+		for _, u := range users {
+			if strings.Contains(strings.ToLower(u.Name), lower) {
+				matches = append(matches, u)
+			}
+		}
+		return matches, nil
+	}
+	doc, err := c.getDocument("/users?" + url.Values{"filter": {name}}.Encode())
+	if err != nil {
+		return nil, err
+	}
+	users := []*User{}
+	doc.Find(".usercard").Each(func(i int, s *goquery.Selection) {
+		a := s.Find("a[href^='/users/']").First()
+		m := userIDRegexp.FindStringSubmatch(a.AttrOr("href", ""))
+		if m == nil {
+			return
+		}
+		u := &User{
+			ID:         atoi(m[1]),
+			Name:       strings.TrimSpace(s.Find(".username").Text()),
+			Reputation: parseCount(s.Find(".reputation-score").Text()),
+		}
+		if len(u.Name) == 0 {
+			u.Name = a.AttrOr("title", "")
+		}
+		users = append(users, u)
+	})
+	return users, nil
+}
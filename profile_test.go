@@ -0,0 +1,75 @@
+package sechat
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestUserProfile(t *testing.T) {
+	c := newTestConn(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/users/thumbs/2":
+			fmt.Fprint(w, `{"id": 2, "name": "Bob", "reputation": 100}`)
+		case r.URL.Path == "/users/2" && r.URL.Query().Get("tab") == "recent":
+			fmt.Fprint(w, `
+<div class="monologue user-2"><div class="messages">
+	<div class="message" id="message-7"><div class="content">recent</div></div>
+</div></div>`)
+		case r.URL.Path == "/users/2":
+			fmt.Fprint(w, `
+<div class="user-status">Working</div>
+<table>
+	<tr><td class="user-keycell">About</td><td class="user-valuecell"> Hi there </td></tr>
+	<tr><td class="user-keycell">Messages</td><td class="user-valuecell">1,234</td></tr>
+	<tr><td class="user-keycell">Parent User</td><td class="user-valuecell">
+		<a href="https://stackoverflow.com/users/9" title="Stack Overflow"><img src="icon.png"></a>
+	</td></tr>
+</table>
+<div id="user-owned-rooms"><div class="roomcard" id="room-201"><span class="room-name">Test</span></div></div>`)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	p, err := c.UserProfile(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Name != "Bob" || p.AboutMe != "Hi there" || p.MessageCount != 1234 ||
+		p.ParentURL != "https://stackoverflow.com/users/9" ||
+		p.Host != "stackoverflow.com" || p.UserMessage != "Working" ||
+		p.Site == nil || p.Site.Caption != "Stack Overflow" {
+		t.Fatalf("%+v", p)
+	}
+	if len(p.RoomsOwned) != 1 || p.RoomsOwned[0].ID != 201 {
+		t.Fatalf("%+v", p.RoomsOwned)
+	}
+	if len(p.Recent) != 1 || p.Recent[0].MessageID != 7 || p.Recent[0].UserName != "Bob" {
+		t.Fatalf("%+v", p.Recent)
+	}
+}
+
+func TestSearchUsers(t *testing.T) {
+	c := newTestConn(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("filter") != "bo" {
+			t.Errorf("unexpected query %s", r.URL.RawQuery)
+		}
+		fmt.Fprint(w, `
+<div class="usercard"><a href="/users/2/bob" title="Bob"></a><span class="reputation-score">1,500</span></div>
+<div class="usercard"><a href="/users/3/bobby"><span class="username">Bobby</span></a></div>
+<div class="usercard"><a href="/rooms/1">not a user</a></div>`)
+	})
+	users, err := c.SearchUsers("bo", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 2 {
+		t.Fatalf("%d != 2", len(users))
+	}
+	if u := users[0]; u.ID != 2 || u.Name != "Bob" || u.Reputation != 1500 {
+		t.Fatalf("%+v", u)
+	}
+	if u := users[1]; u.ID != 3 || u.Name != "Bobby" {
+		t.Fatalf("%+v", u)
+	}
+}
@@ -0,0 +1,158 @@
+package sechat
+
+import "sync"
+
+// Revision describes the content of a message at a particular point in time.
+type Revision struct {
+	Content     string
+	TextContent string
+	Edits       int
+	TimeStamp   int
+}
+
+// MessageHistory describes each revision of a message that has been seen and
+// whether the message was edited or deleted. Edited is set from the edit
+// events themselves, so it is true even if the original revision was never
+// seen. If a message was deleted, LastContent() provides the content it had
+// beforehand.
+type MessageHistory struct {
+	MessageID int
+	RoomID    int
+	UserID    int
+	UserName  string
+	Revisions []*Revision
+	Edited    bool
+	Deleted   bool
+	DeletedAt int
+}
+
+// LastContent returns the most recent content of the message.
+func (h *MessageHistory) LastContent() string {
+	if len(h.Revisions) == 0 {
+		return ""
+	}
+	return h.Revisions[len(h.Revisions)-1].Content
+}
+
+// copy creates a copy of the history so that it can be used without holding
+// the tracker's mutex.
+func (h *MessageHistory) copy() *MessageHistory {
+	c := *h
+	c.Revisions = append([]*Revision{}, h.Revisions...)
+	return &c
+}
+
+// RevisionTracker records every revision of the messages it is given, along
+// with deletions. It implements EventStore so it can be registered with
+// AddEventStore(). Only the most recent messages are kept in memory.
+type RevisionTracker struct {
+	mutex    sync.Mutex
+	size     int
+	order    []int
+	messages map[int]*MessageHistory
+}
+
+// NewRevisionTracker creates a tracker that remembers up to size messages.
+func NewRevisionTracker(size int) *RevisionTracker {
+	return &RevisionTracker{
+		size:     size,
+		order:    []int{},
+		messages: map[int]*MessageHistory{},
+	}
+}
+
+// history returns the history for the event's message, creating it if
+// necessary. The mutex must be held when calling this method.
+func (t *RevisionTracker) history(e *Event) *MessageHistory {
+	h, ok := t.messages[e.MessageID]
+	if !ok {
+		h = &MessageHistory{
+			MessageID: e.MessageID,
+			RoomID:    e.RoomID,
+			UserID:    e.UserID,
+			UserName:  e.UserName,
+			Revisions: []*Revision{},
+		}
+		t.messages[e.MessageID] = h
+		t.order = append(t.order, e.MessageID)
+		for len(t.order) > t.size {
+			delete(t.messages, t.order[0])
+			t.order = t.order[1:]
+		}
+	}
+	return h
+}
+
+// Store records a revision for posted and edited messages and marks deleted
+// messages. Other events are ignored.
+func (t *RevisionTracker) Store(e *Event) error {
+	switch e.EventType {
+	case EventMessagePosted, EventMessageEdited:
+		t.mutex.Lock()
+		defer t.mutex.Unlock()
+		h := t.history(e)
+		if e.EventType == EventMessageEdited || e.MessageEdits > 0 {
+			h.Edited = true
+		}
+		// The same revision may be received more than once (for example, as
+		// both a new message and a reply)
+		for _, r := range h.Revisions {
+			if r.Edits == e.MessageEdits && r.Content == e.Content {
+				return nil
+			}
+		}
+		h.Revisions = append(h.Revisions, &Revision{
+			Content:     e.Content,
+			TextContent: e.TextContent,
+			Edits:       e.MessageEdits,
+			TimeStamp:   e.TimeStamp,
+		})
+	case EventMessageDeleted:
+		t.mutex.Lock()
+		defer t.mutex.Unlock()
+		h := t.history(e)
+		h.Deleted = true
+		h.DeletedAt = e.TimeStamp
+	}
+	return nil
+}
+
+// History returns the history of the specified message or nil if it has not
+// been seen.
+func (t *RevisionTracker) History(message int) *MessageHistory {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if h, ok := t.messages[message]; ok {
+		return h.copy()
+	}
+	return nil
+}
+
+// Edited returns the history of each message that was edited, in the order
+// the messages were first seen.
+func (t *RevisionTracker) Edited() []*MessageHistory {
+	return t.find(func(h *MessageHistory) bool {
+		return h.Edited
+	})
+}
+
+// Deleted returns the history of each message that was deleted, in the order
+// the messages were first seen.
+func (t *RevisionTracker) Deleted() []*MessageHistory {
+	return t.find(func(h *MessageHistory) bool {
+		return h.Deleted
+	})
+}
+
+// find returns copies of the histories that match the provided function.
+func (t *RevisionTracker) find(fn func(*MessageHistory) bool) []*MessageHistory {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	histories := []*MessageHistory{}
+	for _, id := range t.order {
+		if h := t.messages[id]; fn(h) {
+			histories = append(histories, h.copy())
+		}
+	}
+	return histories
+}
@@ -0,0 +1,57 @@
+package sechat
+
+import "testing"
+
+// historyIDs returns the message IDs of the provided histories.
+func historyIDs(histories []*MessageHistory) []int {
+	ids := []int{}
+	for _, h := range histories {
+		ids = append(ids, h.MessageID)
+	}
+	return ids
+}
+
+func TestRevisionTracker(t *testing.T) {
+	tr := NewRevisionTracker(3)
+	for _, e := range []*Event{
+		{EventType: EventMessagePosted, MessageID: 1, Content: "a", TimeStamp: 1},
+		{EventType: EventMessagePosted, MessageID: 1, Content: "a", TimeStamp: 1},
+		{EventType: EventMessageEdited, MessageID: 1, Content: "b", MessageEdits: 1, TimeStamp: 2},
+		{EventType: EventMessagePosted, MessageID: 2, Content: "c", TimeStamp: 3},
+		{EventType: EventMessageDeleted, MessageID: 2, TimeStamp: 4},
+		{EventType: EventMessageEdited, MessageID: 3, Content: "d", MessageEdits: 1, TimeStamp: 5},
+		{EventType: EventUserJoined, MessageID: 4},
+	} {
+		if err := tr.Store(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h := tr.History(1)
+	if h == nil || len(h.Revisions) != 2 || h.LastContent() != "b" || !h.Edited || h.Deleted {
+		t.Fatalf("%+v", h)
+	}
+	h = tr.History(2)
+	if h == nil || h.LastContent() != "c" || h.Edited || !h.Deleted || h.DeletedAt != 4 {
+		t.Fatalf("%+v", h)
+	}
+	if h := tr.History(4); h != nil {
+		t.Fatalf("%+v", h)
+	}
+	// Message 3 was only seen once but the event shows that it was edited
+	if v := historyIDs(tr.Edited()); len(v) != 2 || v[0] != 1 || v[1] != 3 {
+		t.Fatalf("%v != [1 3]", v)
+	}
+	if v := historyIDs(tr.Deleted()); len(v) != 1 || v[0] != 2 {
+		t.Fatalf("%v != [2]", v)
+	}
+	// Modifying a returned history must not affect the tracker
+	tr.History(1).Revisions[0] = nil
+	if tr.History(1).Revisions[0] == nil {
+		t.Fatal("history was modified")
+	}
+	// Storing a fourth message evicts the first
+	tr.Store(&Event{EventType: EventMessagePosted, MessageID: 5, Content: "e"})
+	if h := tr.History(1); h != nil {
+		t.Fatalf("%+v", h)
+	}
+}
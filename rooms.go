@@ -0,0 +1,172 @@
+package sechat
+
+import (
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+const (
+	RoomTabActive = "active"
+	RoomTabPeople = "people"
+	RoomTabNew    = "new"
+)
+
+var (
+	// roomCardRegexp matches the ID of a room card in the room listing.
+	roomCardRegexp = regexp.MustCompile(`^room-(\d+)$`)
+
+	// roomActivityRegexp matches the message count in a room card.
+	roomActivityRegexp = regexp.MustCompile(`\d+`)
+)
+
+// RoomFilter specifies the criteria used for listing rooms. All fields are
+// optional. If Tab is empty, RoomTabActive is used. A nil filter is the same as
+// an empty one.
+type RoomFilter struct {
+	Tab   string
+	Host  string
+	Tag   string
+	Query string
+	Page  int
+}
+
+// values converts the filter into query string parameters.
+func (f *RoomFilter) values() url.Values {
+	if f == nil {
+		f = &RoomFilter{}
+	}
+	v := url.Values{}
+	v.Set("tab", RoomTabActive)
+	if len(f.Tab) != 0 {
+		v.Set("tab", f.Tab)
+	}
+	if len(f.Host) != 0 {
+		v.Set("host", f.Host)
+	}
+	if len(f.Tag) != 0 {
+		v.Set("tags", f.Tag)
+	}
+	if len(f.Query) != 0 {
+		v.Set("filter", f.Query)
+	}
+	if f.Page > 1 {
+		v.Set("page", strconv.Itoa(f.Page))
+	}
+	v.Set("nohide", "true")
+	return v
+}
+
+// parseRoomCard converts a room card from the listing into a Room.
+func parseRoomCard(s *goquery.Selection) *Room {
+	m := roomCardRegexp.FindStringSubmatch(s.AttrOr("id", ""))
+	if m == nil {
+		return nil
+	}
+	r := &Room{
+		ID:   atoi(m[1]),
+		Name: strings.TrimSpace(s.Find(".room-name").Text()),
+	}
+	r.Activity = atoi(
+		roomActivityRegexp.FindString(s.Find(".room-message-count").AttrOr("title", "")),
+	)
+	if t, err := time.Parse(
+		"2006-01-02 15:04:05Z",
+		s.Find(".last-activity").AttrOr("title", ""),
+	); err == nil {
+		r.LastPost = int(t.Unix())
+	}
+	return r
+}
+
+// Rooms retrieves a single page of rooms matching the provided filter. An
+// empty slice is returned when there are no more rooms.
+func (c *Conn) Rooms(filter *RoomFilter) ([]*Room, error) {
+	v := filter.values()
+	doc, err := c.getDocument("/rooms?" + v.Encode())
+	if err != nil {
+		return nil, err
+	}
+	rooms := []*Room{}
+	doc.Find(".roomcard").Each(func(i int, s *goquery.Selection) {
+		if r := parseRoomCard(s); r != nil {
+			rooms = append(rooms, r)
+		}
+	})
+	return rooms, nil
+}
+
+// SearchRooms retrieves the first page of rooms matching the provided query.
+func (c *Conn) SearchRooms(query string) ([]*Room, error) {
+	return c.Rooms(&RoomFilter{Query: query})
+}
+
+// RoomIterator lazily retrieves rooms matching a filter, one page at a time.
+// Call Next() to advance to the next room and Room() to retrieve it.
+type RoomIterator struct {
+	conn   *Conn
+	filter RoomFilter
+	rooms  []*Room
+	room   *Room
+	seen   map[int]struct{}
+	done   bool
+	err    error
+}
+
+// RoomIterator creates an iterator for all rooms matching the provided filter,
+// starting at the filter's page.
+func (c *Conn) RoomIterator(filter *RoomFilter) *RoomIterator {
+	i := &RoomIterator{
+		conn: c,
+		seen: map[int]struct{}{},
+	}
+	if filter != nil {
+		i.filter = *filter
+	}
+	if i.filter.Page < 1 {
+		i.filter.Page = 1
+	}
+	return i
+}
+
+// Next advances to the next room, loading the next page if necessary. False
+// is returned when there are no more rooms or an error occurs.
+func (i *RoomIterator) Next() bool {
+	for len(i.rooms) == 0 {
+		if i.done || i.err != nil {
+			return false
+		}
+		rooms, err := i.conn.Rooms(&i.filter)
+		if err != nil {
+			i.err = err
+			return false
+		}
+		i.filter.Page++
+		// Stop when a page is empty or contains nothing new (some listings
+		// repeat the last page indefinitely)
+		i.done = true
+		for _, r := range rooms {
+			if _, exists := i.seen[r.ID]; !exists {
+				i.seen[r.ID] = struct{}{}
+				i.rooms = append(i.rooms, r)
+				i.done = false
+			}
+		}
+	}
+	i.room, i.rooms = i.rooms[0], i.rooms[1:]
+	return true
+}
+
+// Room returns the current room.
+func (i *RoomIterator) Room() *Room {
+	return i.room
+}
+
+// Err returns the error (if any) that stopped the iterator.
+func (i *RoomIterator) Err() error {
+	return i.err
+}
@@ -0,0 +1,84 @@
+package sechat
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+// newTestConn creates a connection that sends requests to the handler.
+func newTestConn(handler http.HandlerFunc) *Conn {
+	return &Conn{
+		client: &http.Client{Transport: &handlerTransport{handler}},
+		log:    logrus.WithField("context", "test"),
+	}
+}
+
+func TestRoomIterator(t *testing.T) {
+	pages := map[string][]int{
+		"":  {1, 2},
+		"2": {3},
+		"3": {3},
+	}
+	c := newTestConn(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("filter") != "go" {
+			t.Errorf("unexpected query %s", r.URL.RawQuery)
+		}
+		for _, id := range pages[r.URL.Query().Get("page")] {
+			fmt.Fprintf(
+				w,
+				`<div class="roomcard" id="room-%d"><span class="room-name">Room %d</span></div>`,
+				id, id,
+			)
+		}
+	})
+	var (
+		i   = c.RoomIterator(&RoomFilter{Query: "go"})
+		ids = []int{}
+	)
+	for i.Next() {
+		ids = append(ids, i.Room().ID)
+	}
+	if err := i.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(ids, []int{1, 2, 3}) {
+		t.Fatalf("%v", ids)
+	}
+	if i.Next() {
+		t.Fatal("iterator continued after end")
+	}
+}
+
+func TestRoomIteratorError(t *testing.T) {
+	c := newTestConn(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	})
+	i := c.RoomIterator(&RoomFilter{})
+	if i.Next() || i.Err() == nil {
+		t.Fatal("error not reported")
+	}
+}
+
+func TestNilRoomFilter(t *testing.T) {
+	c := newTestConn(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("tab") != RoomTabActive {
+			t.Errorf("unexpected query %s", r.URL.RawQuery)
+		}
+		fmt.Fprint(w, `<div class="roomcard" id="room-1"></div>`)
+	})
+	rooms, err := c.Rooms(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rooms) != 1 {
+		t.Fatalf("%d != 1", len(rooms))
+	}
+	i := c.RoomIterator(nil)
+	if !i.Next() || i.Room().ID != 1 {
+		t.Fatal("nil filter not iterated")
+	}
+}
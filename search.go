@@ -0,0 +1,80 @@
+package sechat
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// searchPageSize is the number of results requested per page.
+const searchPageSize = 50
+
+// parseSearchTime attempts to parse the timestamp on a search result. Only
+// timestamps with a full date are understood; zero is returned otherwise.
+func parseSearchTime(s string) int {
+	s = strings.TrimSpace(s)
+	if t, err := time.Parse("Jan 2 '06 3:04 PM", s); err == nil {
+		return int(t.Unix())
+	}
+	if t, err := time.Parse("Jan 2 3:04 PM", s); err == nil {
+		return int(t.AddDate(time.Now().UTC().Year(), 0, 0).Unix())
+	}
+	return 0
+}
+
+// Search searches for messages containing the provided text. If room or user
+// are non-zero, only messages in that room or from that user are returned.
+// Pages are numbered starting at 1. The messages are returned as events of
+// type EventMessagePosted with Content set to the HTML snippet shown in the
+// results.
+func (c *Conn) Search(query string, room, user, page int) ([]*Event, error) {
+	v := url.Values{}
+	v.Set("q", query)
+	v.Set("pagesize", strconv.Itoa(searchPageSize))
+	v.Set("sort", "newest")
+	if room != 0 {
+		v.Set("room", strconv.Itoa(room))
+	}
+	if user != 0 {
+		v.Set("user", strconv.Itoa(user))
+	}
+	if page > 1 {
+		v.Set("page", strconv.Itoa(page))
+	}
+	doc, err := c.getDocument("/search?" + v.Encode())
+	if err != nil {
+		return nil, err
+	}
+	events := []*Event{}
+	doc.Find(".monologue").Each(func(i int, mono *goquery.Selection) {
+		var (
+			userID, userName = monologueUser(mono)
+			roomID           = room
+			roomName         string
+			timeStamp        = parseSearchTime(mono.Find(".timestamp").First().Text())
+		)
+		if a := mono.Find("a[href*='/rooms/']").First(); a.Length() != 0 {
+			if m := roomRegexp.FindStringSubmatch(a.AttrOr("href", "")); m != nil {
+				roomID = atoi(m[1])
+				roomName = strings.TrimSpace(a.Text())
+			}
+		}
+		mono.Find(".message").Each(func(i int, s *goquery.Selection) {
+			e := parseTranscriptMessage(s)
+			if e == nil {
+				return
+			}
+			e.RoomID = roomID
+			e.RoomName = roomName
+			e.TimeStamp = timeStamp
+			e.UserID = userID
+			e.UserName = userName
+			e.precompute(c.UserName())
+			events = append(events, e)
+		})
+	})
+	return events, nil
+}
@@ -0,0 +1,58 @@
+package sechat
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestParseSearchTime(t *testing.T) {
+	year := time.Now().UTC().Year()
+	for _, test := range []struct {
+		text   string
+		output int
+	}{
+		{"Jan 2 '16 3:04 PM", int(time.Date(2016, 1, 2, 15, 4, 0, 0, time.UTC).Unix())},
+		{" Mar 5 9:30 AM ", int(time.Date(year, 3, 5, 9, 30, 0, 0, time.UTC).Unix())},
+		{"yst 3:04 PM", 0},
+		{"", 0},
+	} {
+		if v := parseSearchTime(test.text); v != test.output {
+			t.Fatalf("%q: %d != %d", test.text, v, test.output)
+		}
+	}
+}
+
+func TestSearch(t *testing.T) {
+	c := newTestConn(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("q") != "build" || q.Get("user") != "2" || q.Get("page") != "3" ||
+			len(q.Get("room")) != 0 {
+			t.Errorf("unexpected query %s", r.URL.RawQuery)
+		}
+		fmt.Fprint(w, `
+<div class="monologue user-2">
+	<div class="signature"><div class="username">Bob</div></div>
+	<div class="timestamp">Jan 2 '16 3:04 PM</div>
+	<a href="/rooms/201/test">Test Room</a>
+	<div class="messages">
+		<div class="message" id="message-5"><div class="content">the build</div></div>
+		<div class="message" id="message-6"><div class="content">another build</div></div>
+	</div>
+</div>`)
+	})
+	events, err := c.Search("build", 0, 2, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("%d != 2", len(events))
+	}
+	e := events[1]
+	if e.MessageID != 6 || e.UserID != 2 || e.UserName != "Bob" || e.RoomID != 201 ||
+		e.RoomName != "Test Room" || e.TextContent != "another build" ||
+		e.TimeStamp != int(time.Date(2016, 1, 2, 15, 4, 0, 0, time.UTC).Unix()) {
+		t.Fatalf("%+v", e)
+	}
+}
@@ -0,0 +1,41 @@
+package sechat
+
+// EventStore persists events received from the chat server.
+type EventStore interface {
+	Store(e *Event) error
+}
+
+// AddEventStore registers a store that every event is written to before it is
+// delivered on the Events channel. Errors are logged but do not prevent the
+// event from being delivered.
+func (c *Conn) AddEventStore(s EventStore) {
+	c.storeMutex.Lock()
+	defer c.storeMutex.Unlock()
+	c.stores = append(c.stores, s)
+}
+
+// RemoveEventStore unregisters a store added with AddEventStore. Once it
+// returns, the store will not receive any more events, so it is safe to close
+// the store. It must not be called from within a store's Store method.
+func (c *Conn) RemoveEventStore(s EventStore) {
+	c.storeMutex.Lock()
+	defer c.storeMutex.Unlock()
+	for i, v := range c.stores {
+		if v == s {
+			c.stores = append(c.stores[:i:i], c.stores[i+1:]...)
+			return
+		}
+	}
+}
+
+// store writes an event to each of the registered stores. The lock is held
+// while the stores are invoked so that RemoveEventStore waits for them.
+func (c *Conn) store(e *Event) {
+	c.storeMutex.RLock()
+	defer c.storeMutex.RUnlock()
+	for _, s := range c.stores {
+		if err := s.Store(e); err != nil {
+			c.log.Error(err)
+		}
+	}
+}
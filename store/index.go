@@ -0,0 +1,161 @@
+package store
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/nathan-osman/go-sechat"
+)
+
+// indexSchema creates the full-text table. The rowid of each row is the ID of
+// the message so that edits and deletions can find it.
+const indexSchema = `
+CREATE VIRTUAL TABLE IF NOT EXISTS messages USING fts5 (
+	text_content,
+	room_id UNINDEXED,
+	user_id UNINDEXED,
+	user_name UNINDEXED,
+	time_stamp UNINDEXED
+);
+`
+
+// SearchQuery specifies the text to search for along with optional filters.
+// Fields other than Text with their zero value are ignored.
+type SearchQuery struct {
+	Text  string
+	Room  int
+	User  int
+	Since time.Time
+	Until time.Time
+	Limit int
+}
+
+// Index maintains a full-text index of the messages received from the chat
+// server. Edited messages are reindexed and deleted messages are removed.
+type Index struct {
+	db *sql.DB
+}
+
+// NewIndex opens (or creates) the index at the specified path.
+func NewIndex(path string) (*Index, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(indexSchema); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &Index{db: db}, nil
+}
+
+// Store updates the index using the event. Only posted, edited, and deleted
+// messages affect the index; other events are ignored.
+func (i *Index) Store(e *sechat.Event) error {
+	switch e.EventType {
+	case sechat.EventMessagePosted:
+		_, err := i.db.Exec(
+			`INSERT OR REPLACE INTO messages
+			(rowid, text_content, room_id, user_id, user_name, time_stamp)
+			VALUES (?, ?, ?, ?, ?, ?)`,
+			e.MessageID,
+			e.TextContent,
+			e.RoomID,
+			e.UserID,
+			e.UserName,
+			e.TimeStamp,
+		)
+		return err
+	case sechat.EventMessageEdited:
+		// The time of the original message is kept; if the message was posted
+		// before indexing began, the time of the edit is used instead
+		res, err := i.db.Exec(
+			`UPDATE messages SET text_content = ? WHERE rowid = ?`,
+			e.TextContent,
+			e.MessageID,
+		)
+		if err != nil {
+			return err
+		}
+		if n, err := res.RowsAffected(); err != nil || n != 0 {
+			return err
+		}
+		_, err = i.db.Exec(
+			`INSERT INTO messages
+			(rowid, text_content, room_id, user_id, user_name, time_stamp)
+			VALUES (?, ?, ?, ?, ?, ?)`,
+			e.MessageID,
+			e.TextContent,
+			e.RoomID,
+			e.UserID,
+			e.UserName,
+			e.TimeStamp,
+		)
+		return err
+	case sechat.EventMessageDeleted:
+		_, err := i.db.Exec(`DELETE FROM messages WHERE rowid = ?`, e.MessageID)
+		return err
+	}
+	return nil
+}
+
+// Search retrieves the messages matching the query, best matches first. The
+// syntax of Text is that of SQLite's FTS5 extension. The messages are returned
+// as events of type EventMessagePosted with only TextContent filled in.
+func (i *Index) Search(q *SearchQuery) ([]*sechat.Event, error) {
+	var (
+		where = []string{"messages MATCH ?"}
+		args  = []interface{}{q.Text}
+	)
+	add := func(clause string, arg interface{}) {
+		where = append(where, clause)
+		args = append(args, arg)
+	}
+	if q.Room != 0 {
+		add("room_id = ?", q.Room)
+	}
+	if q.User != 0 {
+		add("user_id = ?", q.User)
+	}
+	if !q.Since.IsZero() {
+		add("time_stamp >= ?", q.Since.Unix())
+	}
+	if !q.Until.IsZero() {
+		add("time_stamp < ?", q.Until.Unix())
+	}
+	query := `SELECT rowid, text_content, room_id, user_id, user_name,
+		time_stamp FROM messages WHERE ` + strings.Join(where, " AND ") +
+		` ORDER BY rank`
+	if q.Limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, q.Limit)
+	}
+	rows, err := i.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	events := []*sechat.Event{}
+	for rows.Next() {
+		e := &sechat.Event{EventType: sechat.EventMessagePosted}
+		if err := rows.Scan(
+			&e.MessageID,
+			&e.TextContent,
+			&e.RoomID,
+			&e.UserID,
+			&e.UserName,
+			&e.TimeStamp,
+		); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// Close closes the index.
+func (i *Index) Close() error {
+	return i.db.Close()
+}
@@ -0,0 +1,69 @@
+package store
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nathan-osman/go-sechat"
+)
+
+func TestIndex(t *testing.T) {
+	i, err := NewIndex(filepath.Join(t.TempDir(), "index.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer i.Close()
+	for _, e := range []*sechat.Event{
+		{EventType: sechat.EventMessagePosted, MessageID: 1, RoomID: 1, UserID: 1, TimeStamp: 100, TextContent: "the build failed"},
+		{EventType: sechat.EventMessagePosted, MessageID: 2, RoomID: 2, UserID: 2, TimeStamp: 200, TextContent: "the build passed"},
+		{EventType: sechat.EventMessagePosted, MessageID: 3, RoomID: 1, UserID: 2, TimeStamp: 300, TextContent: "deploying now"},
+		{EventType: sechat.EventMessageEdited, MessageID: 3, RoomID: 1, UserID: 2, TimeStamp: 400, TextContent: "deploying the build now"},
+		{EventType: sechat.EventMessageEdited, MessageID: 4, RoomID: 1, UserID: 1, TimeStamp: 500, TextContent: "earlier build message"},
+		{EventType: sechat.EventMessagePosted, MessageID: 5, RoomID: 1, UserID: 1, TimeStamp: 600, TextContent: "build removed"},
+		{EventType: sechat.EventMessageDeleted, MessageID: 5, RoomID: 1},
+		{EventType: sechat.EventUserJoined, RoomID: 1, UserID: 3},
+	} {
+		if err := i.Store(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, test := range []struct {
+		query  *SearchQuery
+		output []int
+	}{
+		{&SearchQuery{Text: "build"}, []int{1, 2, 3, 4}},
+		{&SearchQuery{Text: "deploying"}, []int{3}},
+		{&SearchQuery{Text: "build", Room: 2}, []int{2}},
+		{&SearchQuery{Text: "build", User: 1}, []int{1, 4}},
+		{&SearchQuery{Text: "build", Since: time.Unix(200, 0), Until: time.Unix(500, 0)}, []int{2, 3}},
+		{&SearchQuery{Text: "removed"}, []int{}},
+	} {
+		events, err := i.Search(test.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids := map[int]bool{}
+		for _, e := range events {
+			ids[e.MessageID] = true
+		}
+		if len(ids) != len(test.output) {
+			t.Fatalf("%+v: %d result(s) != %d", test.query, len(ids), len(test.output))
+		}
+		for _, id := range test.output {
+			if !ids[id] {
+				t.Fatalf("%+v: message %d missing", test.query, id)
+			}
+		}
+	}
+	events, err := i.Search(&SearchQuery{Text: "deploying"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := events[0]; e.TextContent != "deploying the build now" || e.TimeStamp != 300 {
+		t.Fatalf("%+v", e)
+	}
+	if events, _ := i.Search(&SearchQuery{Text: "build", Limit: 2}); len(events) != 2 {
+		t.Fatalf("%d != 2", len(events))
+	}
+}
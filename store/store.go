@@ -0,0 +1,211 @@
+/*
+Package store provides durable storage for events received from the chat
+server. Events are written to an SQLite database using a pure-Go driver, so no
+C compiler is required.
+
+To record every event received by a connection:
+
+    s, err := store.NewSQLite("events.db")
+    if err != nil {
+        // handle error
+    }
+    defer s.Close()
+    c.AddEventStore(s)
+    defer c.RemoveEventStore(s)
+
+The events can be retrieved later with Query():
+
+    events, err := s.Query(&store.Query{Room: 201, Type: sechat.EventMessagePosted})
+
+A full-text index of messages can be maintained in the same way using
+NewIndex() and searched with Search().
+*/
+package store
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/nathan-osman/go-sechat"
+	_ "modernc.org/sqlite"
+)
+
+// schema creates the table and indices used to store events. Events received
+// over the websocket have unique IDs; those without one (such as events
+// created from transcripts) are always inserted.
+const schema = `
+CREATE TABLE IF NOT EXISTS events (
+	rowid          INTEGER PRIMARY KEY AUTOINCREMENT,
+	id             INTEGER NOT NULL,
+	content        TEXT NOT NULL,
+	event_type     INTEGER NOT NULL,
+	message_edits  INTEGER NOT NULL,
+	message_id     INTEGER NOT NULL,
+	message_stars  INTEGER NOT NULL,
+	moved          BOOLEAN NOT NULL,
+	parent_id      INTEGER NOT NULL,
+	room_id        INTEGER NOT NULL,
+	room_name      TEXT NOT NULL,
+	show_parent    BOOLEAN NOT NULL,
+	target_user_id INTEGER NOT NULL,
+	time_stamp     INTEGER NOT NULL,
+	user_id        INTEGER NOT NULL,
+	user_name      TEXT NOT NULL,
+	is_mention     BOOLEAN NOT NULL,
+	text_content   TEXT NOT NULL,
+	markdown       TEXT NOT NULL
+);
+CREATE UNIQUE INDEX IF NOT EXISTS events_id ON events (id) WHERE id != 0;
+CREATE INDEX IF NOT EXISTS events_room_id ON events (room_id, time_stamp);
+CREATE INDEX IF NOT EXISTS events_user_id ON events (user_id, time_stamp);
+CREATE INDEX IF NOT EXISTS events_event_type ON events (event_type, time_stamp);
+CREATE INDEX IF NOT EXISTS events_time_stamp ON events (time_stamp);
+CREATE INDEX IF NOT EXISTS events_message_id ON events (message_id);
+`
+
+// columns lists the columns in the order used for inserting and selecting.
+const columns = `id, content, event_type, message_edits, message_id,
+	message_stars, moved, parent_id, room_id, room_name, show_parent,
+	target_user_id, time_stamp, user_id, user_name, is_mention, text_content,
+	markdown`
+
+// Query specifies which events to retrieve. Fields with their zero value are
+// ignored.
+type Query struct {
+	Room      int
+	User      int
+	Type      int
+	MessageID int
+	Since     time.Time
+	Until     time.Time
+	Limit     int
+}
+
+// SQLite stores events in an SQLite database.
+type SQLite struct {
+	db *sql.DB
+}
+
+// NewSQLite opens (or creates) the database at the specified path.
+func NewSQLite(path string) (*SQLite, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite only permits a single writer
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &SQLite{db: db}, nil
+}
+
+// Store writes the event to the database. Events that have already been
+// stored are ignored.
+func (s *SQLite) Store(e *sechat.Event) error {
+	_, err := s.db.Exec(
+		`INSERT OR IGNORE INTO events (`+columns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		e.ID,
+		e.Content,
+		e.EventType,
+		e.MessageEdits,
+		e.MessageID,
+		e.MessageStars,
+		e.Moved,
+		e.ParentID,
+		e.RoomID,
+		e.RoomName,
+		e.ShowParent,
+		e.TargetUserID,
+		e.TimeStamp,
+		e.UserID,
+		e.UserName,
+		e.IsMention,
+		e.TextContent,
+		e.Markdown,
+	)
+	return err
+}
+
+// Query retrieves the events that match the query, oldest first.
+func (s *SQLite) Query(q *Query) ([]*sechat.Event, error) {
+	var (
+		where = []string{"1"}
+		args  = []interface{}{}
+	)
+	add := func(clause string, arg interface{}) {
+		where = append(where, clause)
+		args = append(args, arg)
+	}
+	if q.Room != 0 {
+		add("room_id = ?", q.Room)
+	}
+	if q.User != 0 {
+		add("user_id = ?", q.User)
+	}
+	if q.Type != 0 {
+		add("event_type = ?", q.Type)
+	}
+	if q.MessageID != 0 {
+		add("message_id = ?", q.MessageID)
+	}
+	if !q.Since.IsZero() {
+		add("time_stamp >= ?", q.Since.Unix())
+	}
+	if !q.Until.IsZero() {
+		add("time_stamp < ?", q.Until.Unix())
+	}
+	query := `SELECT ` + columns + ` FROM events WHERE ` +
+		strings.Join(where, " AND ") + ` ORDER BY time_stamp, rowid`
+	if q.Limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, q.Limit)
+	}
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	events := []*sechat.Event{}
+	for rows.Next() {
+		e := &sechat.Event{}
+		if err := rows.Scan(
+			&e.ID,
+			&e.Content,
+			&e.EventType,
+			&e.MessageEdits,
+			&e.MessageID,
+			&e.MessageStars,
+			&e.Moved,
+			&e.ParentID,
+			&e.RoomID,
+			&e.RoomName,
+			&e.ShowParent,
+			&e.TargetUserID,
+			&e.TimeStamp,
+			&e.UserID,
+			&e.UserName,
+			&e.IsMention,
+			&e.TextContent,
+			&e.Markdown,
+		); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// DB provides direct access to the database for queries not covered by
+// Query().
+func (s *SQLite) DB() *sql.DB {
+	return s.db
+}
+
+// Close closes the database.
+func (s *SQLite) Close() error {
+	return s.db.Close()
+}
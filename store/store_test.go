@@ -0,0 +1,90 @@
+package store
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/nathan-osman/go-sechat"
+)
+
+func TestSQLite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.db")
+	s, err := NewSQLite(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	full := &sechat.Event{
+		ID:           1,
+		Content:      "<b>hi</b>",
+		EventType:    sechat.EventMessageReply,
+		MessageEdits: 2,
+		MessageID:    10,
+		MessageStars: 3,
+		Moved:        true,
+		ParentID:     9,
+		RoomID:       1,
+		RoomName:     "Test Room",
+		ShowParent:   true,
+		TargetUserID: 5,
+		TimeStamp:    100,
+		UserID:       1,
+		UserName:     "Alice",
+		IsMention:    true,
+		TextContent:  "hi",
+		Markdown:     "**hi**",
+	}
+	for _, e := range []*sechat.Event{
+		full,
+		{ID: 1, EventType: sechat.EventMessagePosted, MessageID: 99},
+		{ID: 2, EventType: sechat.EventMessagePosted, MessageID: 11, RoomID: 2, UserID: 2, TimeStamp: 200},
+		{ID: 3, EventType: sechat.EventMessageEdited, MessageID: 10, RoomID: 1, UserID: 1, TimeStamp: 300},
+		{EventType: sechat.EventMessagePosted, MessageID: 12, RoomID: 1, UserID: 2, TimeStamp: 50},
+		{EventType: sechat.EventMessagePosted, MessageID: 12, RoomID: 1, UserID: 2, TimeStamp: 50},
+	} {
+		if err := s.Store(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, test := range []struct {
+		query  *Query
+		output []int
+	}{
+		{&Query{}, []int{12, 12, 10, 11, 10}},
+		{&Query{Room: 1}, []int{12, 12, 10, 10}},
+		{&Query{User: 2}, []int{12, 12, 11}},
+		{&Query{Type: sechat.EventMessageEdited}, []int{10}},
+		{&Query{MessageID: 10}, []int{10, 10}},
+		{&Query{Since: time.Unix(100, 0), Until: time.Unix(300, 0)}, []int{10, 11}},
+		{&Query{Limit: 2}, []int{12, 12}},
+	} {
+		events, err := s.Query(test.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids := []int{}
+		for _, e := range events {
+			ids = append(ids, e.MessageID)
+		}
+		if !reflect.DeepEqual(ids, test.output) {
+			t.Fatalf("%+v: %v != %v", test.query, ids, test.output)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// Reopening the database must preserve the events
+	s, err = NewSQLite(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	events, err := s.Query(&Query{Type: sechat.EventMessageReply})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || !reflect.DeepEqual(events[0], full) {
+		t.Fatalf("%+v", events)
+	}
+}
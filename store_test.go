@@ -0,0 +1,34 @@
+package sechat
+
+import (
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+// countingStore counts the events it receives.
+type countingStore struct {
+	count int
+}
+
+func (s *countingStore) Store(e *Event) error {
+	s.count++
+	return nil
+}
+
+func TestEventStores(t *testing.T) {
+	var (
+		c  = &Conn{log: logrus.WithField("context", "test")}
+		s1 = &countingStore{}
+		s2 = &countingStore{}
+	)
+	c.AddEventStore(s1)
+	c.AddEventStore(s2)
+	c.store(&Event{})
+	c.RemoveEventStore(s1)
+	c.store(&Event{})
+	c.RemoveEventStore(s1)
+	if s1.count != 1 || s2.count != 2 {
+		t.Fatalf("%d, %d", s1.count, s2.count)
+	}
+}
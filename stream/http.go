@@ -0,0 +1,169 @@
+package stream
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// keepAlive is the interval at which idle connections are pinged.
+const keepAlive = 30 * time.Second
+
+// parseRooms parses a comma-separated list of room IDs.
+func parseRooms(v string) (map[int]bool, error) {
+	rooms := map[int]bool{}
+	for _, r := range strings.Split(v, ",") {
+		if r = strings.TrimSpace(r); len(r) == 0 {
+			continue
+		}
+		id, err := strconv.Atoi(r)
+		if err != nil {
+			return nil, fmt.Errorf("invalid room %q", r)
+		}
+		rooms[id] = true
+	}
+	return rooms, nil
+}
+
+// ServeHTTP subscribes the client to events, using a websocket if the request
+// is a websocket handshake and Server-Sent Events otherwise.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	rooms, err := parseRooms(r.URL.Query().Get("rooms"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	lastID := r.Header.Get("Last-Event-ID")
+	if len(lastID) == 0 {
+		lastID = r.URL.Query().Get("last_event_id")
+	}
+	var after int64
+	if len(lastID) != 0 {
+		if after, err = strconv.ParseInt(lastID, 10, 64); err != nil {
+			http.Error(w, "invalid event ID", http.StatusBadRequest)
+			return
+		}
+	}
+	if websocket.IsWebSocketUpgrade(r) {
+		s.serveWebSocket(w, r, rooms, after)
+	} else {
+		s.serveSSE(w, r, rooms, after)
+	}
+}
+
+// serveSSE sends events to the client as Server-Sent Events.
+func (s *Server) serveSSE(w http.ResponseWriter, r *http.Request, rooms map[int]bool, after int64) {
+	f, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	sub, backlog := s.subscribe(rooms, after)
+	defer s.unsubscribe(sub)
+	send := func(m *Message) error {
+		if m.Reset {
+			_, err := fmt.Fprintf(w, "id: %d\nevent: reset\ndata: {}\n\n", m.ID)
+			return err
+		}
+		b, err := json.Marshal(m.Event)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "id: %d\ndata: %s\n\n", m.ID, b)
+		return err
+	}
+	for _, m := range backlog {
+		if err := send(m); err != nil {
+			return
+		}
+	}
+	f.Flush()
+	ticker := time.NewTicker(keepAlive)
+	defer ticker.Stop()
+	for {
+		select {
+		case m, ok := <-sub.ch:
+			if !ok {
+				return
+			}
+			if err := send(m); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if _, err := fmt.Fprint(w, ":\n\n"); err != nil {
+				return
+			}
+		case <-r.Context().Done():
+			return
+		}
+		f.Flush()
+	}
+}
+
+// serveWebSocket sends events to the client as websocket messages.
+func (s *Server) serveWebSocket(w http.ResponseWriter, r *http.Request, rooms map[int]bool, after int64) {
+	u := &websocket.Upgrader{CheckOrigin: s.CheckOrigin}
+	conn, err := u.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	sub, backlog := s.subscribe(rooms, after)
+	defer s.unsubscribe(sub)
+	// Messages from the client are discarded but must be read in order to
+	// process control frames and detect when the connection is closed
+	doneCh := make(chan bool)
+	go func() {
+		defer close(doneCh)
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+	send := func(m *Message) error {
+		conn.SetWriteDeadline(time.Now().Add(keepAlive))
+		return conn.WriteJSON(m)
+	}
+	for _, m := range backlog {
+		if err := send(m); err != nil {
+			return
+		}
+	}
+	ticker := time.NewTicker(keepAlive)
+	defer ticker.Stop()
+	for {
+		select {
+		case m, ok := <-sub.ch:
+			if !ok {
+				conn.WriteControl(
+					websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseGoingAway, ""),
+					time.Now().Add(time.Second),
+				)
+				return
+			}
+			if err := send(m); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(keepAlive)); err != nil {
+				return
+			}
+		case <-doneCh:
+			return
+		}
+	}
+}
@@ -0,0 +1,195 @@
+/*
+Package stream re-broadcasts the events from a single connection to any number
+of HTTP clients using Server-Sent Events or websockets. This allows many
+consumers (such as dashboards) to receive live events without each of them
+logging in to chat.
+
+Each event is assigned a sequence number when it is received. The most recent
+events are kept in a ring buffer so that clients that reconnect can resume
+from the last sequence number they received. Sequence numbers start from 1
+each time the server is created.
+
+To serve the events from a connection:
+
+    s := stream.New(c.Events, 1000)
+    defer s.Close()
+    http.Handle("/events", s)
+
+Clients connect with a normal GET request to receive events as Server-Sent
+Events or with a websocket handshake to receive events as websocket messages.
+The following query parameters are recognized:
+
+    rooms           comma-separated list of room IDs (all rooms by default)
+    last_event_id   resume after the event with this sequence number
+
+SSE clients that reconnect automatically send the Last-Event-ID header, which
+is used in place of last_event_id. Each SSE message uses the sequence number
+as its ID and the event as its data. Each websocket message is a JSON object
+with "id" and "event" keys.
+
+Clients that do not keep up with the stream are disconnected; they may
+reconnect and resume from the last event received.
+
+If some of the events after the requested sequence number are no longer
+available (because the ring buffer has wrapped or the server has restarted),
+a reset message is sent before any events so that the client knows to resync
+(for example, by fetching the transcript). Over SSE, this is an event named
+"reset"; over a websocket, it is an object with "reset" set to true. In both
+cases, the ID is the sequence number of the most recent event, and the
+buffered events that are still available follow.
+*/
+package stream
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/nathan-osman/go-sechat"
+)
+
+// subscriberBuffer is the number of messages that may be waiting to be sent to
+// a subscriber before it is disconnected.
+const subscriberBuffer = 100
+
+// Message is an event and its sequence number. If Reset is set, Event is nil
+// and the message indicates that events were missed.
+type Message struct {
+	ID    int64         `json:"id"`
+	Reset bool          `json:"reset,omitempty"`
+	Event *sechat.Event `json:"event,omitempty"`
+}
+
+// subscriber receives messages for the rooms it is interested in.
+type subscriber struct {
+	rooms map[int]bool
+	ch    chan *Message
+}
+
+// match determines if the subscriber is interested in the message.
+func (s *subscriber) match(m *Message) bool {
+	return len(s.rooms) == 0 || s.rooms[m.Event.RoomID]
+}
+
+// Server consumes events and broadcasts them to subscribers.
+type Server struct {
+	mutex       sync.Mutex
+	buffer      []*Message
+	next        int
+	seq         int64
+	subscribers map[*subscriber]struct{}
+	closed      bool
+
+	// CheckOrigin is used to validate the origin of websocket requests; if
+	// nil, only requests from the same origin are permitted
+	CheckOrigin func(r *http.Request) bool
+}
+
+// New creates a server that broadcasts events from the channel, keeping the
+// specified number of events for clients that resume.
+func New(events <-chan *sechat.Event, size int) *Server {
+	if size < 1 {
+		size = 1
+	}
+	s := &Server{
+		buffer:      make([]*Message, size),
+		subscribers: map[*subscriber]struct{}{},
+	}
+	go s.run(events)
+	return s
+}
+
+// run broadcasts events until the channel is closed.
+func (s *Server) run(events <-chan *sechat.Event) {
+	for e := range events {
+		s.broadcast(e)
+	}
+	s.Close()
+}
+
+// broadcast stores the event in the ring buffer and sends it to subscribers.
+// Subscribers that are too far behind are removed.
+func (s *Server) broadcast(e *sechat.Event) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.closed {
+		return
+	}
+	s.seq++
+	m := &Message{ID: s.seq, Event: e}
+	s.buffer[s.next] = m
+	s.next = (s.next + 1) % len(s.buffer)
+	for sub := range s.subscribers {
+		if !sub.match(m) {
+			continue
+		}
+		select {
+		case sub.ch <- m:
+		default:
+			delete(s.subscribers, sub)
+			close(sub.ch)
+		}
+	}
+}
+
+// subscribe registers a subscriber and returns the buffered messages after
+// the specified sequence number, preceded by a reset message if any have been
+// lost. Both are done while holding the lock so that no messages are missed or
+// duplicated.
+func (s *Server) subscribe(rooms map[int]bool, after int64) (*subscriber, []*Message) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	sub := &subscriber{
+		rooms: rooms,
+		ch:    make(chan *Message, subscriberBuffer),
+	}
+	if s.closed {
+		close(sub.ch)
+		return sub, nil
+	}
+	s.subscribers[sub] = struct{}{}
+	backlog := []*Message{}
+	if after <= 0 || after == s.seq {
+		return sub, backlog
+	}
+	// The oldest buffered message follows the newest one
+	oldest := s.buffer[s.next]
+	if oldest == nil {
+		oldest = s.buffer[0]
+	}
+	if after > s.seq || oldest.ID > after+1 {
+		backlog = append(backlog, &Message{ID: s.seq, Reset: true})
+	}
+	if after < s.seq {
+		for i := 0; i < len(s.buffer); i++ {
+			m := s.buffer[(s.next+i)%len(s.buffer)]
+			if m != nil && m.ID > after && sub.match(m) {
+				backlog = append(backlog, m)
+			}
+		}
+	}
+	return sub, backlog
+}
+
+// unsubscribe removes a subscriber if it has not already been removed.
+func (s *Server) unsubscribe(sub *subscriber) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, ok := s.subscribers[sub]; ok {
+		delete(s.subscribers, sub)
+		close(sub.ch)
+	}
+}
+
+// Close disconnects all subscribers. Events received after Close are ignored.
+func (s *Server) Close() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.closed {
+		return
+	}
+	s.closed = true
+	for sub := range s.subscribers {
+		close(sub.ch)
+	}
+	s.subscribers = map[*subscriber]struct{}{}
+}
@@ -0,0 +1,147 @@
+package stream
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/nathan-osman/go-sechat"
+)
+
+// newTestServer creates a server without a running event loop so that events
+// can be broadcast synchronously.
+func newTestServer(size int) *Server {
+	return &Server{
+		buffer:      make([]*Message, size),
+		subscribers: map[*subscriber]struct{}{},
+	}
+}
+
+// ids formats the IDs of the messages, with resets shown as negative IDs.
+func ids(messages []*Message) string {
+	v := []int64{}
+	for _, m := range messages {
+		if m.Reset {
+			v = append(v, -m.ID)
+		} else {
+			v = append(v, m.ID)
+		}
+	}
+	return fmt.Sprint(v)
+}
+
+func TestSubscribe(t *testing.T) {
+	s := newTestServer(3)
+	// Resuming before any events have been received (after a restart)
+	sub, backlog := s.subscribe(nil, 1)
+	s.unsubscribe(sub)
+	if len(backlog) != 1 || !backlog[0].Reset {
+		t.Fatalf("%s", ids(backlog))
+	}
+	for i := 1; i <= 5; i++ {
+		s.broadcast(&sechat.Event{ID: i, RoomID: i % 2})
+	}
+	for _, test := range []struct {
+		rooms  map[int]bool
+		after  int64
+		output string
+	}{
+		{nil, 0, "[]"},
+		{nil, 5, "[]"},
+		{nil, 4, "[5]"},
+		{nil, 2, "[3 4 5]"},
+		{nil, 1, "[-5 3 4 5]"},
+		{nil, 9, "[-5]"},
+		{map[int]bool{1: true}, 2, "[3 5]"},
+		{map[int]bool{0: true}, 1, "[-5 4]"},
+	} {
+		sub, backlog := s.subscribe(test.rooms, test.after)
+		s.unsubscribe(sub)
+		if v := ids(backlog); v != test.output {
+			t.Fatalf("%v, %d: %s != %s", test.rooms, test.after, v, test.output)
+		}
+	}
+}
+
+func TestSlowSubscriber(t *testing.T) {
+	s := newTestServer(1)
+	sub, _ := s.subscribe(nil, 0)
+	for i := 0; i <= subscriberBuffer; i++ {
+		s.broadcast(&sechat.Event{})
+	}
+	for range sub.ch {
+	}
+	if len(s.subscribers) != 0 {
+		t.Fatal("slow subscriber was not removed")
+	}
+	s.unsubscribe(sub)
+}
+
+func TestHTTP(t *testing.T) {
+	var (
+		ch = make(chan *sechat.Event)
+		s  = New(ch, 2)
+	)
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+	for i := 1; i <= 3; i++ {
+		ch <- &sechat.Event{ID: i, RoomID: 1}
+	}
+	// Sending another event ensures that the previous ones were stored
+	ch <- &sechat.Event{ID: 4, RoomID: 2}
+
+	// SSE client resuming from an event that is no longer buffered
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"?rooms=1", nil)
+	req.Header.Set("Last-Event-ID", "1")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	r := bufio.NewReader(res.Body)
+	for _, expected := range []string{"id: 4", "event: reset", "data: {}", "", "id: 3"} {
+		l, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		if l = strings.TrimRight(l, "\n"); l != expected {
+			t.Fatalf("%q != %q", l, expected)
+		}
+	}
+
+	// Websocket client resuming from a buffered event
+	u := "ws" + strings.TrimPrefix(srv.URL, "http") + "?last_event_id=3"
+	conn, _, err := websocket.DefaultDialer.Dial(u, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	ch <- &sechat.Event{ID: 5, RoomID: 1}
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	for _, expected := range []int64{4, 5} {
+		m := &Message{}
+		if err := conn.ReadJSON(m); err != nil {
+			t.Fatal(err)
+		}
+		if m.ID != expected || m.Reset {
+			t.Fatalf("%+v", m)
+		}
+	}
+
+	for _, q := range []string{"?rooms=abc", "?last_event_id=abc"} {
+		res, err := http.Get(srv.URL + q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != http.StatusBadRequest {
+			t.Fatalf("%s: %d", q, res.StatusCode)
+		}
+	}
+	close(ch)
+}
@@ -0,0 +1,39 @@
+package sechat
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/sirupsen/logrus"
+)
+
+// handlerTransport sends requests directly to a handler instead of the chat
+// server.
+type handlerTransport struct {
+	handler http.Handler
+}
+
+func (h *handlerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	w := httptest.NewRecorder()
+	h.handler.ServeHTTP(w, req)
+	return w.Result(), nil
+}
+
+// NewTestConn creates a connection for testing code that uses this package.
+// Every request is sent to the handler instead of the chat server and no
+// login takes place. Nothing is received on Events unless the channel is
+// replaced by the test.
+func NewTestConn(handler http.Handler, user int, userName string) *Conn {
+	c := &Conn{
+		Events:   make(chan *Event),
+		closeCh:  make(chan bool),
+		closedCh: make(chan bool),
+		client:   &http.Client{Transport: &handlerTransport{handler}},
+		log:      logrus.WithField("context", "test"),
+		user:     user,
+		userName: userName,
+	}
+	c.Directory = newUserDirectory(c)
+	close(c.closedCh)
+	return c
+}
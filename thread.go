@@ -0,0 +1,169 @@
+package sechat
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+var ErrMessageNotFound = errors.New("unable to find message")
+
+// Message retrieves a single message using its transcript page. The message
+// is returned as an event of type EventMessagePosted.
+func (c *Conn) Message(message int) (*Event, error) {
+	doc, err := c.getDocument(fmt.Sprintf("/transcript/message/%d", message))
+	if err != nil {
+		return nil, err
+	}
+	s := doc.Find(fmt.Sprintf("#message-%d", message))
+	e := parseTranscriptMessage(s)
+	if e == nil {
+		return nil, ErrMessageNotFound
+	}
+	e.UserID, e.UserName = monologueUser(s.ParentsFiltered(".monologue"))
+	if a := doc.Find(".room-name a").First(); a.Length() != 0 {
+		if m := roomRegexp.FindStringSubmatch(a.AttrOr("href", "")); m != nil {
+			e.RoomID = atoi(m[1])
+			e.RoomName = strings.TrimSpace(a.Text())
+		}
+	}
+	e.precompute(c.UserName())
+	return e, nil
+}
+
+// Thread retrieves the chain of replies that led to the specified message. The
+// first event is the message that started the thread and the last is the
+// message itself.
+func (c *Conn) Thread(message int) ([]*Event, error) {
+	var (
+		events = []*Event{}
+		seen   = map[int]struct{}{}
+	)
+	for message != 0 {
+		if _, exists := seen[message]; exists {
+			break
+		}
+		seen[message] = struct{}{}
+		e, err := c.Message(message)
+		if err != nil {
+			return nil, err
+		}
+		events = append([]*Event{e}, events...)
+		message = e.ParentID
+	}
+	return events, nil
+}
+
+// ThreadTracker groups the messages it is given into threads by following
+// replies. A thread is identified by the ID of the message that started it.
+// Only the most recent messages are kept in memory.
+type ThreadTracker struct {
+	mutex    sync.Mutex
+	size     int
+	order    []int
+	messages map[int]*Event
+	roots    map[int]int
+	threads  map[int][]int
+}
+
+// NewThreadTracker creates a tracker that remembers up to size messages.
+func NewThreadTracker(size int) *ThreadTracker {
+	return &ThreadTracker{
+		size:     size,
+		order:    []int{},
+		messages: map[int]*Event{},
+		roots:    map[int]int{},
+		threads:  map[int][]int{},
+	}
+}
+
+// evict removes the oldest message when the tracker is full. The mutex must be
+// held when calling this method.
+func (t *ThreadTracker) evict() {
+	for len(t.order) > t.size {
+		id := t.order[0]
+		t.order = t.order[1:]
+		root := t.roots[id]
+		ids := t.threads[root]
+		for i, v := range ids {
+			if v == id {
+				t.threads[root] = append(ids[:i:i], ids[i+1:]...)
+				break
+			}
+		}
+		if len(t.threads[root]) == 0 {
+			delete(t.threads, root)
+		}
+		delete(t.messages, id)
+		delete(t.roots, id)
+	}
+}
+
+// Add records an event. Only events that contain messages are used; edits
+// replace the content of messages already recorded. The ID of the thread that
+// the message belongs to is returned (or 0 if the event was not used).
+func (t *ThreadTracker) Add(e *Event) int {
+	switch e.EventType {
+	case EventMessagePosted, EventMessageReply, EventUserMentioned, EventMessageEdited:
+	default:
+		return 0
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if _, exists := t.messages[e.MessageID]; exists {
+		if e.EventType == EventMessageEdited {
+			t.messages[e.MessageID] = e
+		}
+		return t.roots[e.MessageID]
+	}
+	root := e.MessageID
+	if r, exists := t.roots[e.ParentID]; exists {
+		root = r
+	} else if e.ParentID != 0 {
+		// The parent was not seen (or has been evicted) so it is treated as
+		// the start of the thread
+		root = e.ParentID
+	}
+	t.messages[e.MessageID] = e
+	t.roots[e.MessageID] = root
+	t.threads[root] = append(t.threads[root], e.MessageID)
+	t.order = append(t.order, e.MessageID)
+	t.evict()
+	return root
+}
+
+// Root returns the ID of the thread that the message belongs to or 0 if the
+// message has not been seen.
+func (t *ThreadTracker) Root(message int) int {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.roots[message]
+}
+
+// Thread returns the messages in the thread that the specified message belongs
+// to, in the order that they were received.
+func (t *ThreadTracker) Thread(message int) []*Event {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	events := []*Event{}
+	root, exists := t.roots[message]
+	if !exists {
+		return events
+	}
+	for _, id := range t.threads[root] {
+		events = append(events, t.messages[id])
+	}
+	return events
+}
+
+// Parent returns the message that the specified message replied to, if it has
+// been seen.
+func (t *ThreadTracker) Parent(message int) *Event {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if e, exists := t.messages[message]; exists {
+		return t.messages[e.ParentID]
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package sechat
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// threadPage extends transcriptPage with a reply to the reply and a pair of
+// messages that reply to each other.
+const threadPage = transcriptPage + `
+<div class="monologue user-1">
+	<div class="signature"><div class="username">Alice</div></div>
+	<div class="messages">
+		<div class="message" id="message-12">
+			<a class="reply-info" href="/transcript/message/11#11"></a>
+			<div class="content">bye</div>
+		</div>
+		<div class="message" id="message-20">
+			<a class="reply-info" href="/transcript/message/21#21"></a>
+			<div class="content">a</div>
+		</div>
+		<div class="message" id="message-21">
+			<a class="reply-info" href="/transcript/message/20#20"></a>
+			<div class="content">b</div>
+		</div>
+	</div>
+</div>`
+
+func newThreadTestConn() *Conn {
+	return newTestConn(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/transcript/message/") ||
+			r.URL.Path == "/transcript/message/404" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, threadPage)
+	})
+}
+
+// messageIDs returns the IDs of the provided events.
+func messageIDs(events []*Event) string {
+	ids := []string{}
+	for _, e := range events {
+		ids = append(ids, fmt.Sprint(e.MessageID))
+	}
+	return strings.Join(ids, ",")
+}
+
+func TestMessage(t *testing.T) {
+	c := newThreadTestConn()
+	e, err := c.Message(11)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.MessageID != 11 || e.UserID != 2 || e.UserName != "Bob" ||
+		e.RoomID != 201 || e.RoomName != "Test Room" || e.ParentID != 10 ||
+		e.TextContent != "hi" {
+		t.Fatalf("%+v", e)
+	}
+	if _, err := c.Message(99); err != ErrMessageNotFound {
+		t.Fatalf("%v != %v", err, ErrMessageNotFound)
+	}
+	if _, err := c.Message(404); err == nil {
+		t.Fatal("error expected")
+	}
+}
+
+func TestThread(t *testing.T) {
+	c := newThreadTestConn()
+	for _, test := range []struct {
+		message int
+		output  string
+	}{
+		{10, "10"},
+		{12, "10,11,12"},
+		{20, "21,20"},
+	} {
+		events, err := c.Thread(test.message)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v := messageIDs(events); v != test.output {
+			t.Fatalf("%d: %s != %s", test.message, v, test.output)
+		}
+	}
+	if _, err := c.Thread(99); err != ErrMessageNotFound {
+		t.Fatalf("%v != %v", err, ErrMessageNotFound)
+	}
+}
+
+func TestThreadTracker(t *testing.T) {
+	tr := NewThreadTracker(3)
+	for _, test := range []struct {
+		event *Event
+		root  int
+	}{
+		{&Event{EventType: EventMessagePosted, MessageID: 1}, 1},
+		{&Event{EventType: EventMessageReply, MessageID: 2, ParentID: 1}, 1},
+		{&Event{EventType: EventUserJoined, MessageID: 3}, 0},
+		{&Event{EventType: EventMessagePosted, MessageID: 4, ParentID: 9}, 9},
+		{&Event{EventType: EventMessageEdited, MessageID: 2, ParentID: 1, Content: "x"}, 1},
+	} {
+		if v := tr.Add(test.event); v != test.root {
+			t.Fatalf("%d: %d != %d", test.event.MessageID, v, test.root)
+		}
+	}
+	if v := messageIDs(tr.Thread(2)); v != "1,2" {
+		t.Fatalf("%s != 1,2", v)
+	}
+	if e := tr.Thread(2)[1]; e.Content != "x" {
+		t.Fatalf("%q != x", e.Content)
+	}
+	if e := tr.Parent(2); e == nil || e.MessageID != 1 {
+		t.Fatalf("%+v", e)
+	}
+	if e := tr.Parent(4); e != nil {
+		t.Fatalf("%+v", e)
+	}
+	// Adding a fourth message evicts the first
+	tr.Add(&Event{EventType: EventMessageReply, MessageID: 5, ParentID: 2})
+	if v := tr.Root(1); v != 0 {
+		t.Fatalf("%d != 0", v)
+	}
+	if v := messageIDs(tr.Thread(5)); v != "2,5" {
+		t.Fatalf("%s != 2,5", v)
+	}
+	if v := len(tr.Thread(1)); v != 0 {
+		t.Fatalf("%d != 0", v)
+	}
+}
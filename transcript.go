@@ -0,0 +1,247 @@
+package sechat
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+var (
+	// transcriptUserRegexp matches the user class on a monologue.
+	transcriptUserRegexp = regexp.MustCompile(`\buser-(-?\d+)\b`)
+
+	// transcriptMessageRegexp matches the ID of a message in a transcript.
+	transcriptMessageRegexp = regexp.MustCompile(`^message-(\d+)$`)
+
+	// transcriptParentRegexp matches the link to a reply's parent message.
+	transcriptParentRegexp = regexp.MustCompile(`/transcript/message/(\d+)`)
+
+	// transcriptRangeRegexp matches a link to an hour range of a busy day.
+	transcriptRangeRegexp = regexp.MustCompile(`/transcript/\d+/\d+/\d+/\d+/(\d+-\d+)`)
+)
+
+// transcriptPath returns the path to the transcript for the specified day.
+func transcriptPath(room int, date time.Time) string {
+	return fmt.Sprintf(
+		"/transcript/%d/%d/%d/%d",
+		room,
+		date.Year(),
+		date.Month(),
+		date.Day(),
+	)
+}
+
+// parseTranscriptMessage converts a single message in a monologue into an
+// event. The room, user, and time are left for the caller to fill in.
+func parseTranscriptMessage(s *goquery.Selection) *Event {
+	m := transcriptMessageRegexp.FindStringSubmatch(s.AttrOr("id", ""))
+	if m == nil {
+		return nil
+	}
+	content, _ := s.Find(".content").Html()
+	e := &Event{
+		Content:   strings.TrimSpace(content),
+		EventType: EventMessagePosted,
+		MessageID: atoi(m[1]),
+	}
+	if stars := s.Find(".stars"); stars.Length() != 0 {
+		e.MessageStars = atoi(strings.TrimSpace(stars.Find(".times").Text()))
+		if e.MessageStars == 0 {
+			e.MessageStars = 1
+		}
+	}
+	if p := transcriptParentRegexp.FindStringSubmatch(
+		s.Find(".reply-info").AttrOr("href", ""),
+	); p != nil {
+		e.ParentID = atoi(p[1])
+		e.ShowParent = true
+	}
+	return e
+}
+
+// monologueUser returns the ID and name of the author of a monologue.
+func monologueUser(mono *goquery.Selection) (int, string) {
+	var (
+		userID   int
+		userName = strings.TrimSpace(mono.Find(".signature .username").Text())
+	)
+	if m := transcriptUserRegexp.FindStringSubmatch(mono.AttrOr("class", "")); m != nil {
+		userID = atoi(m[1])
+	}
+	return userID, userName
+}
+
+// parseTranscript converts the messages on a transcript page into events.
+// Timestamps only appear on some messages, so each message inherits the time
+// of the last timestamp that preceded it.
+func (c *Conn) parseTranscript(doc *goquery.Document, room int, date time.Time) []*Event {
+	var (
+		events   = []*Event{}
+		midnight = time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+		last     = midnight
+		roomName = strings.TrimSpace(doc.Find(".room-name").First().Text())
+	)
+	doc.Find(".monologue").Each(func(i int, mono *goquery.Selection) {
+		userID, userName := monologueUser(mono)
+		mono.Find(".messages").Children().Each(func(i int, s *goquery.Selection) {
+			if s.HasClass("timestamp") {
+				if t, err := time.Parse("3:04 PM", strings.TrimSpace(s.Text())); err == nil {
+					last = midnight.Add(
+						time.Duration(t.Hour())*time.Hour +
+							time.Duration(t.Minute())*time.Minute,
+					)
+				}
+				return
+			}
+			e := parseTranscriptMessage(s)
+			if e == nil {
+				return
+			}
+			e.RoomID = room
+			e.RoomName = roomName
+			e.TimeStamp = int(last.Unix())
+			e.UserID = userID
+			e.UserName = userName
+			e.precompute(c.UserName())
+			events = append(events, e)
+		})
+	})
+	return events
+}
+
+// Transcript retrieves all messages posted in a room on the specified day
+// (in UTC). Busy days are split into hour ranges by the chat server; each
+// range is retrieved in turn. The messages are returned as events of type
+// EventMessagePosted with their content in HTML. Use MessageSource() to
+// obtain the original markdown for a message.
+func (c *Conn) Transcript(room int, date time.Time) ([]*Event, error) {
+	date = date.UTC()
+	path := transcriptPath(room, date)
+	doc, err := c.getDocument(path)
+	if err != nil {
+		return nil, err
+	}
+	ranges := []string{}
+	doc.Find(".pager a").Each(func(i int, s *goquery.Selection) {
+		if m := transcriptRangeRegexp.FindStringSubmatch(s.AttrOr("href", "")); m != nil {
+			ranges = append(ranges, m[1])
+		}
+	})
+	if len(ranges) == 0 {
+		return c.parseTranscript(doc, room, date), nil
+	}
+	var (
+		events = []*Event{}
+		seen   = map[int]struct{}{}
+	)
+	for _, r := range ranges {
+		doc, err := c.getDocument(fmt.Sprintf("%s/%s", path, r))
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range c.parseTranscript(doc, room, date) {
+			if _, exists := seen[e.MessageID]; !exists {
+				events = append(events, e)
+				seen[e.MessageID] = struct{}{}
+			}
+		}
+	}
+	return events, nil
+}
+
+// MessageSource retrieves the original markdown for the specified message. If
+// the message doesn't exist or can't be viewed (in which case chat responds
+// with an HTML page instead of the source), ErrMessageNotFound is returned.
+func (c *Conn) MessageSource(message int) (string, error) {
+	req, err := c.newRequest(
+		http.MethodGet,
+		fmt.Sprintf("https://chat.stackexchange.com/message/%d?plain=true", message),
+		nil,
+	)
+	if err != nil {
+		return "", err
+	}
+	res, err := c.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	switch {
+	case res.StatusCode == http.StatusNotFound:
+		return "", ErrMessageNotFound
+	case res.StatusCode != http.StatusOK:
+		return "", errors.New(res.Status)
+	case strings.HasPrefix(res.Header.Get("Content-Type"), "text/html"):
+		return "", ErrMessageNotFound
+	}
+	b, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// TranscriptIterator streams the messages in a room across a range of days,
+// retrieving one day at a time. Call Next() to advance to the next message and
+// Event() to retrieve it.
+type TranscriptIterator struct {
+	conn   *Conn
+	room   int
+	date   time.Time
+	end    time.Time
+	events []*Event
+	event  *Event
+	err    error
+}
+
+// TranscriptIterator creates an iterator for the messages posted in a room
+// from the first day to the last day (inclusive).
+func (c *Conn) TranscriptIterator(room int, from, to time.Time) *TranscriptIterator {
+	from, to = from.UTC(), to.UTC()
+	return &TranscriptIterator{
+		conn: c,
+		room: room,
+		date: time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC),
+		end:  time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, time.UTC),
+	}
+}
+
+// Next advances to the next message, loading the next day if necessary. False
+// is returned when the end of the range is reached or an error occurs.
+func (i *TranscriptIterator) Next() bool {
+	for len(i.events) == 0 {
+		if i.err != nil || i.date.After(i.end) {
+			return false
+		}
+		events, err := i.conn.Transcript(i.room, i.date)
+		if err != nil {
+			i.err = err
+			return false
+		}
+		i.events = events
+		i.date = i.date.AddDate(0, 0, 1)
+	}
+	i.event, i.events = i.events[0], i.events[1:]
+	return true
+}
+
+// Date returns the day that will be retrieved next.
+func (i *TranscriptIterator) Date() time.Time {
+	return i.date
+}
+
+// Event returns the current message.
+func (i *TranscriptIterator) Event() *Event {
+	return i.event
+}
+
+// Err returns the error (if any) that stopped the iterator.
+func (i *TranscriptIterator) Err() error {
+	return i.err
+}
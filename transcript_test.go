@@ -0,0 +1,95 @@
+package sechat
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// transcriptPage is a transcript containing two monologues. The second
+// message is a starred reply to the first.
+const transcriptPage = `
+<div id="info"><div class="room-name"><a href="/rooms/201/test">Test Room</a></div></div>
+<div class="monologue user-1">
+	<div class="signature"><div class="username">Alice</div></div>
+	<div class="messages">
+		<div class="timestamp">2:05 PM</div>
+		<div class="message" id="message-10"><div class="content">hello <b>world</b></div></div>
+	</div>
+</div>
+<div class="monologue user-2">
+	<div class="signature"><div class="username">Bob</div></div>
+	<div class="messages">
+		<div class="message" id="message-11">
+			<a class="reply-info" href="/transcript/message/10#10"></a>
+			<div class="content">hi</div>
+			<span class="stars"><span class="times">3</span></span>
+		</div>
+	</div>
+</div>`
+
+func TestTranscript(t *testing.T) {
+	c := newTestConn(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/transcript/201/2016/1/2" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, transcriptPage)
+	})
+	events, err := c.Transcript(201, time.Date(2016, 1, 2, 12, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("%d != 2", len(events))
+	}
+	var (
+		e1 = events[0]
+		e2 = events[1]
+		ts = int(time.Date(2016, 1, 2, 14, 5, 0, 0, time.UTC).Unix())
+	)
+	if e1.MessageID != 10 || e1.UserID != 1 || e1.UserName != "Alice" ||
+		e1.RoomID != 201 || e1.RoomName != "Test Room" || e1.TimeStamp != ts ||
+		e1.TextContent != "hello world" || e1.Markdown != "hello **world**" {
+		t.Fatalf("%+v", e1)
+	}
+	if e2.MessageID != 11 || e2.UserID != 2 || e2.ParentID != 10 ||
+		e2.MessageStars != 3 || e2.TimeStamp != ts {
+		t.Fatalf("%+v", e2)
+	}
+}
+
+func TestMessageSource(t *testing.T) {
+	c := newTestConn(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/message/1":
+			w.Header().Set("Content-Type", "text/plain")
+			fmt.Fprint(w, "**source**")
+		case "/message/2":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			fmt.Fprint(w, "<html>log in</html>")
+		case "/message/3":
+			http.Error(w, "error", http.StatusInternalServerError)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	for _, test := range []struct {
+		message  int
+		output   string
+		err      bool
+		notFound bool
+	}{
+		{1, "**source**", false, false},
+		{2, "", true, true},
+		{3, "", true, false},
+		{4, "", true, true},
+	} {
+		v, err := c.MessageSource(test.message)
+		if v != test.output || (err != nil) != test.err ||
+			(test.notFound && err != ErrMessageNotFound) {
+			t.Fatalf("%d: %q, %v", test.message, v, err)
+		}
+	}
+}
@@ -0,0 +1,331 @@
+/*
+Package webhook forwards events received from the chat server to HTTP
+endpoints so that services written in other languages can react to chat
+activity.
+
+Each event that matches a target's filter is POSTed to the target's URL as
+JSON. If the target has a secret, the body is signed using HMAC-SHA256 and the
+signature is sent in the X-Sechat-Signature header as "sha256=<hex>". Failed
+deliveries are retried with exponential backoff; events that still cannot be
+delivered are appended to the dead-letter file (if configured).
+
+To forward events from a connection:
+
+    s, err := webhook.New(&webhook.Config{
+        Targets: []*webhook.Target{
+            {
+                URL:    "https://example.com/hook",
+                Secret: "s3cret",
+                Filter: webhook.Filter{Types: []int{sechat.EventMessagePosted}},
+            },
+        },
+        DeadLetterPath: "undelivered.jsonl",
+    })
+    if err != nil {
+        // handle error
+    }
+    defer s.Close()
+    c.AddEventStore(s)
+    defer c.RemoveEventStore(s)
+
+Events passed to Store after the sink is closed are dropped.
+*/
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/nathan-osman/go-sechat"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultMaxRetries     = 5
+	defaultInitialBackoff = time.Second
+	defaultMaxBackoff     = 5 * time.Minute
+	defaultQueueSize      = 1000
+)
+
+// NoRetries may be used for Config.MaxRetries to deliver each event only once.
+const NoRetries = -1
+
+var (
+	ErrQueueFull = errors.New("webhook queue is full")
+	ErrClosed    = errors.New("webhook sink is closed")
+)
+
+// Filter selects the events sent to a target. Empty fields match everything.
+type Filter struct {
+	Rooms []int
+	Types []int
+	Users []int
+}
+
+// contains determines if v is in the list or the list is empty.
+func contains(list []int, v int) bool {
+	if len(list) == 0 {
+		return true
+	}
+	for _, i := range list {
+		if i == v {
+			return true
+		}
+	}
+	return false
+}
+
+// Match determines if the event should be sent.
+func (f *Filter) Match(e *sechat.Event) bool {
+	return contains(f.Rooms, e.RoomID) &&
+		contains(f.Types, e.EventType) &&
+		contains(f.Users, e.UserID)
+}
+
+// Target is an endpoint that receives events.
+type Target struct {
+	URL    string
+	Secret string
+	Filter Filter
+}
+
+// Config controls delivery. Only Targets is required. MaxRetries is the number
+// of times a failed delivery is retried; zero selects the default, so use
+// NoRetries to disable retrying.
+type Config struct {
+	Targets        []*Target
+	MaxRetries     int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	QueueSize      int
+	DeadLetterPath string
+	Client         *http.Client
+}
+
+// deadLetter is written to the dead-letter file for each undelivered event.
+type deadLetter struct {
+	URL   string        `json:"url"`
+	Error string        `json:"error"`
+	Time  time.Time     `json:"time"`
+	Event *sechat.Event `json:"event"`
+}
+
+// Sink delivers events to the configured targets in the background. Each
+// target has its own queue so that a slow target does not delay the others.
+type Sink struct {
+	cfg        *Config
+	log        *logrus.Entry
+	mutex      sync.RWMutex
+	queues     map[*Target]chan *sechat.Event
+	closed     bool
+	closeCh    chan bool
+	wg         sync.WaitGroup
+	deadMutex  sync.Mutex
+	deadLetter *os.File
+}
+
+// New creates a sink and starts delivering events to the targets.
+func New(cfg *Config) (*Sink, error) {
+	c := *cfg
+	switch {
+	case c.MaxRetries == 0:
+		c.MaxRetries = defaultMaxRetries
+	case c.MaxRetries < 0:
+		c.MaxRetries = 0
+	}
+	if c.InitialBackoff == 0 {
+		c.InitialBackoff = defaultInitialBackoff
+	}
+	if c.MaxBackoff == 0 {
+		c.MaxBackoff = defaultMaxBackoff
+	}
+	if c.QueueSize == 0 {
+		c.QueueSize = defaultQueueSize
+	}
+	if c.Client == nil {
+		c.Client = &http.Client{Timeout: 30 * time.Second}
+	}
+	s := &Sink{
+		cfg:     &c,
+		log:     logrus.WithField("context", "webhook"),
+		queues:  map[*Target]chan *sechat.Event{},
+		closeCh: make(chan bool),
+	}
+	if len(c.DeadLetterPath) != 0 {
+		f, err := os.OpenFile(c.DeadLetterPath, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+		if err != nil {
+			return nil, err
+		}
+		s.deadLetter = f
+	}
+	for _, t := range c.Targets {
+		ch := make(chan *sechat.Event, c.QueueSize)
+		s.queues[t] = ch
+		s.wg.Add(1)
+		go s.run(t, ch)
+	}
+	return s, nil
+}
+
+// Store queues the event for each target whose filter matches. It never
+// blocks; if a target's queue is full, the event is written to the dead-letter
+// file and ErrQueueFull is returned. If the sink is closed, the event is
+// dropped and ErrClosed is returned.
+func (s *Sink) Store(e *sechat.Event) error {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	if s.closed {
+		return ErrClosed
+	}
+	var err error
+	for t, ch := range s.queues {
+		if !t.Filter.Match(e) {
+			continue
+		}
+		select {
+		case ch <- e:
+		default:
+			s.dead(t, e, ErrQueueFull)
+			err = ErrQueueFull
+		}
+	}
+	return err
+}
+
+// sign returns the signature header value for a body.
+func sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// permanentError indicates that retrying the delivery will not help.
+type permanentError struct {
+	status string
+}
+
+// Error returns a description of the error.
+func (p *permanentError) Error() string {
+	return p.status
+}
+
+// deliver makes a single attempt to send an event to a target.
+func (s *Sink) deliver(t *Target, e *sechat.Event) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return &permanentError{err.Error()}
+	}
+	req, err := http.NewRequest(http.MethodPost, t.URL, bytes.NewReader(body))
+	if err != nil {
+		return &permanentError{err.Error()}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "go-sechat (https://qms.li/gsc)")
+	req.Header.Set("X-Sechat-Event", fmt.Sprintf("%d", e.EventType))
+	if len(t.Secret) != 0 {
+		req.Header.Set("X-Sechat-Signature", sign(t.Secret, body))
+	}
+	res, err := s.cfg.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	switch {
+	case res.StatusCode < 300:
+		return nil
+	case res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= 500:
+		return errors.New(res.Status)
+	default:
+		return &permanentError{res.Status}
+	}
+}
+
+// run delivers the events in a target's queue until the sink is closed. When
+// closing, the events remaining in the queue are still delivered but are not
+// retried.
+func (s *Sink) run(t *Target, ch chan *sechat.Event) {
+	defer s.wg.Done()
+	for e := range ch {
+		var (
+			backoff = s.cfg.InitialBackoff
+			err     error
+		)
+		for attempt := 0; ; attempt++ {
+			if err = s.deliver(t, e); err == nil {
+				break
+			}
+			if _, ok := err.(*permanentError); ok {
+				break
+			}
+			s.log.Warnf("delivery to %s failed: %s", t.URL, err)
+			if attempt == s.cfg.MaxRetries || !s.wait(backoff) {
+				break
+			}
+			if backoff *= 2; backoff > s.cfg.MaxBackoff {
+				backoff = s.cfg.MaxBackoff
+			}
+		}
+		if err != nil {
+			s.dead(t, e, err)
+		}
+	}
+}
+
+// wait pauses before the next attempt, returning false if the sink is closed
+// in the meantime.
+func (s *Sink) wait(backoff time.Duration) bool {
+	select {
+	case <-time.After(backoff):
+		return true
+	case <-s.closeCh:
+		return false
+	}
+}
+
+// dead records an event that could not be delivered.
+func (s *Sink) dead(t *Target, e *sechat.Event, err error) {
+	s.log.Errorf("unable to deliver event %d to %s: %s", e.ID, t.URL, err)
+	if s.deadLetter == nil {
+		return
+	}
+	s.deadMutex.Lock()
+	defer s.deadMutex.Unlock()
+	if err := json.NewEncoder(s.deadLetter).Encode(&deadLetter{
+		URL:   t.URL,
+		Error: err.Error(),
+		Time:  time.Now(),
+		Event: e,
+	}); err != nil {
+		s.log.Error(err)
+	}
+}
+
+// Close stops accepting events, waits for queued events to be delivered (or
+// written to the dead-letter file), and closes the dead-letter file.
+func (s *Sink) Close() error {
+	s.mutex.Lock()
+	if s.closed {
+		s.mutex.Unlock()
+		return nil
+	}
+	s.closed = true
+	close(s.closeCh)
+	for _, ch := range s.queues {
+		close(ch)
+	}
+	s.mutex.Unlock()
+	s.wg.Wait()
+	if s.deadLetter != nil {
+		return s.deadLetter.Close()
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+package webhook
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nathan-osman/go-sechat"
+)
+
+func TestFilter(t *testing.T) {
+	e := &sechat.Event{RoomID: 1, EventType: sechat.EventMessagePosted, UserID: 2}
+	for _, test := range []struct {
+		filter Filter
+		output bool
+	}{
+		{Filter{}, true},
+		{Filter{Rooms: []int{1, 3}}, true},
+		{Filter{Rooms: []int{3}}, false},
+		{Filter{Types: []int{sechat.EventMessagePosted}}, true},
+		{Filter{Types: []int{sechat.EventMessageEdited}}, false},
+		{Filter{Users: []int{2}}, true},
+		{Filter{Rooms: []int{1}, Users: []int{3}}, false},
+	} {
+		if v := test.filter.Match(e); v != test.output {
+			t.Fatalf("%+v: %v != %v", test.filter, v, test.output)
+		}
+	}
+}
+
+func TestSign(t *testing.T) {
+	// Computed with: printf '{}' | openssl dgst -sha256 -hmac secret
+	const expected = "sha256=77325902caca812dc259733aacd046b73817372c777b8d95b402647474516e13"
+	if v := sign("secret", []byte("{}")); v != expected {
+		t.Fatalf("%q != %q", v, expected)
+	}
+}
+
+// readDeadLetters reads the entries in a dead-letter file.
+func readDeadLetters(t *testing.T, path string) []*deadLetter {
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	lines := []*deadLetter{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		d := &deadLetter{}
+		if err := json.Unmarshal(scanner.Bytes(), d); err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, d)
+	}
+	return lines
+}
+
+func TestDelivery(t *testing.T) {
+	var (
+		mutex    sync.Mutex
+		attempts int
+		received []int
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		if r.Header.Get("X-Sechat-Signature") != sign("secret", b) {
+			t.Error("invalid signature")
+		}
+		mutex.Lock()
+		defer mutex.Unlock()
+		switch r.URL.Path {
+		case "/flaky":
+			if attempts++; attempts < 3 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+		case "/bad":
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		e := &sechat.Event{}
+		json.Unmarshal(b, e)
+		received = append(received, e.ID)
+	}))
+	defer srv.Close()
+	deadPath := filepath.Join(t.TempDir(), "dead.jsonl")
+	s, err := New(&Config{
+		Targets: []*Target{
+			{URL: srv.URL + "/flaky", Secret: "secret", Filter: Filter{Rooms: []int{1}}},
+			{URL: srv.URL + "/bad", Secret: "secret", Filter: Filter{Rooms: []int{2}}},
+		},
+		InitialBackoff: time.Millisecond,
+		DeadLetterPath: deadPath,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Store(&sechat.Event{ID: 1, RoomID: 1})
+	s.Store(&sechat.Event{ID: 2, RoomID: 2})
+	s.Store(&sechat.Event{ID: 3, RoomID: 3})
+	// Retries stop when the sink is closed, so wait for them to finish
+	for i := 0; i < 100; i++ {
+		mutex.Lock()
+		n := len(received)
+		mutex.Unlock()
+		if n != 0 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Store(&sechat.Event{ID: 4, RoomID: 1}); err != ErrClosed {
+		t.Fatalf("%v != %v", err, ErrClosed)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if len(received) != 1 || received[0] != 1 || attempts != 3 {
+		t.Fatalf("%v, %d attempt(s)", received, attempts)
+	}
+	lines := readDeadLetters(t, deadPath)
+	if len(lines) != 1 || lines[0].Event.ID != 2 || lines[0].URL != srv.URL+"/bad" {
+		t.Fatalf("%+v", lines)
+	}
+}
+
+func TestRetries(t *testing.T) {
+	// Waiting after the final attempt would prevent the event from reaching
+	// the dead-letter file when the backoff is long
+	for _, test := range []struct {
+		maxRetries int
+		backoff    time.Duration
+		attempts   int
+	}{
+		{NoRetries, time.Hour, 1},
+		{2, time.Millisecond, 3},
+	} {
+		var (
+			mutex    sync.Mutex
+			attempts int
+		)
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mutex.Lock()
+			attempts++
+			mutex.Unlock()
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		deadPath := filepath.Join(t.TempDir(), "dead.jsonl")
+		s, err := New(&Config{
+			Targets:        []*Target{{URL: srv.URL}},
+			MaxRetries:     test.maxRetries,
+			InitialBackoff: test.backoff,
+			MaxBackoff:     time.Hour,
+			DeadLetterPath: deadPath,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.Store(&sechat.Event{ID: 1})
+		var lines []*deadLetter
+		for i := 0; i < 100 && len(lines) == 0; i++ {
+			time.Sleep(10 * time.Millisecond)
+			lines = readDeadLetters(t, deadPath)
+		}
+		s.Close()
+		srv.Close()
+		if len(lines) != 1 || attempts != test.attempts {
+			t.Fatalf("%d: %d line(s), %d attempt(s)", test.maxRetries, len(lines), attempts)
+		}
+	}
+}